@@ -0,0 +1,353 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// permission yang dicek oleh handler. PermissionAll berlaku untuk semua
+// permission, dipakai role admin. PermissionUsersWrite untuk mengubah data
+// user lain (profil, alamat, like), user selalu boleh mengubah datanya
+// sendiri.
+const (
+	PermissionAll               = "*"
+	PermissionUsersWrite        = "users.write"
+	PermissionUsersDelete       = "users.delete"
+	PermissionUsersReactivate   = "users.reactivate"
+	PermissionWalletsWrite      = "wallets.write"
+	PermissionProductsWrite     = "products.write"
+	PermissionGuestBookModerate = "guest_books.moderate"
+	PermissionRolesManage       = "roles.manage"
+)
+
+// DefaultRoles role bawaan yang dibuat oleh DefineDefaultRoles
+var DefaultRoles = map[string][]string{
+	"admin":           {PermissionAll},
+	"user_manager":    {PermissionUsersWrite, PermissionUsersDelete, PermissionUsersReactivate},
+	"wallet_manager":  {PermissionWalletsWrite},
+	"catalog_manager": {PermissionProductsWrite},
+	"moderator":       {PermissionGuestBookModerate},
+}
+
+type Permission struct {
+	ID        int64     `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	Name      string    `gorm:"column:name;size:100;uniqueIndex" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (p *Permission) TableName() string {
+	return "permissions"
+}
+
+type Role struct {
+	ID          int64        `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	Name        string       `gorm:"column:name;size:100;uniqueIndex" json:"name"`
+	CreatedAt   time.Time    `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+	Permissions []Permission `gorm:"many2many:role_permissions;foreignKey:id;joinForeignKey:role_id;references:id;joinReferences:permission_id" json:"permissions,omitempty"`
+}
+
+func (r *Role) TableName() string {
+	return "roles"
+}
+
+// UserRole join table User.Roles. Resource kosong berarti role berlaku di
+// semua resource, "product:*" untuk semua resource satu jenis dan
+// "product:P001" untuk satu resource saja.
+type UserRole struct {
+	UserID    string    `gorm:"primary_key;column:user_id;size:100" json:"user_id"`
+	RoleID    int64     `gorm:"primary_key;column:role_id" json:"role_id"`
+	Resource  string    `gorm:"primary_key;column:resource;size:191;default:''" json:"resource"`
+	GrantedBy string    `gorm:"column:granted_by;size:100" json:"granted_by"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Role      Role      `gorm:"foreignKey:role_id;references:id" json:"role"`
+}
+
+func (u *UserRole) TableName() string {
+	return "user_roles"
+}
+
+// MigrateRoles buat tabel role dan permission, join table user_roles dipasang
+// ke User.Roles supaya Preload("Roles") ikut pakai kolom resource
+func MigrateRoles(db *gorm.DB) error {
+	if err := db.SetupJoinTable(&User{}, "Roles", &UserRole{}); err != nil {
+		return err
+	}
+	return db.Migrator().AutoMigrate(&Permission{}, &Role{}, &UserRole{})
+}
+
+// AccessControl cek permission user lewat role yang dimilikinya. permission
+// per user disimpan di memori selama CacheTTL, grant dan revoke lewat
+// AccessControl yang sama langsung menghapus cache user itu, perubahan dari
+// instance lain baru kelihatan setelah TTL lewat. role dan user_roles tidak
+// per tenant, pemanggil harus memastikan user nya anggota tenant request
+// (lihat requireTenantMember di handler).
+type AccessControl struct {
+	db       *gorm.DB
+	CacheTTL time.Duration
+	Now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedGrants
+}
+
+func NewAccessControl(db *gorm.DB) *AccessControl {
+	return &AccessControl{
+		db:       db,
+		CacheTTL: time.Minute,
+		Now:      time.Now,
+		cache:    map[string]cachedGrants{},
+	}
+}
+
+// grant satu permission yang dimiliki user di satu resource
+type grant struct {
+	Permission string
+	Resource   string
+}
+
+type cachedGrants struct {
+	grants    []grant
+	expiresAt time.Time
+}
+
+// Can true kalau salah satu role user punya permission di resource.
+// resource kosong berarti permission global, cuma role yang di grant
+// tanpa resource yang dihitung. user yang dinonaktifkan tidak punya
+// permission apa pun.
+func (a *AccessControl) Can(ctx context.Context, userID string, permission string, resource string) (bool, error) {
+	grants, err := a.grants(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, grant := range grants {
+		if (grant.Permission == permission || grant.Permission == PermissionAll) && resourceMatches(grant.Resource, resource) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Authorize sama dengan Can tapi return ErrPermissionDenied kalau tidak boleh
+func (a *AccessControl) Authorize(ctx context.Context, userID string, permission string, resource string) error {
+	allowed, err := a.Can(ctx, userID, permission, resource)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		if resource == "" {
+			return fmt.Errorf("%w: %s", ErrPermissionDenied, permission)
+		}
+		return fmt.Errorf("%w: %s on %s", ErrPermissionDenied, permission, resource)
+	}
+	return nil
+}
+
+func resourceMatches(granted string, resource string) bool {
+	if granted == "" || granted == resource {
+		return true
+	}
+	kind, ok := strings.CutSuffix(granted, ":*")
+	return ok && strings.HasPrefix(resource, kind+":")
+}
+
+func (a *AccessControl) grants(ctx context.Context, userID string) ([]grant, error) {
+	now := a.Now()
+	a.mu.Lock()
+	cached, ok := a.cache[userID]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.grants, nil
+	}
+
+	var grants []grant
+	err := a.db.WithContext(ctx).
+		Table("user_roles").
+		Select("permissions.name AS permission", "user_roles.resource").
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ?", userID).
+		Scan(&grants).Error
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.cache[userID] = cachedGrants{grants: grants, expiresAt: now.Add(a.CacheTTL)}
+	a.mu.Unlock()
+	return grants, nil
+}
+
+// Invalidate hapus cache permission satu user, userID kosong untuk semua user
+func (a *AccessControl) Invalidate(userID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if userID == "" {
+		a.cache = map[string]cachedGrants{}
+		return
+	}
+	delete(a.cache, userID)
+}
+
+// DefineRole buat role kalau belum ada lalu ganti permission nya persis
+// dengan yang diberikan, permission yang belum ada ikut dibuat
+func (a *AccessControl) DefineRole(ctx context.Context, name string, permissions ...string) (*Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &ValidationError{Fields: map[string]string{"name": "must not be empty"}}
+	}
+
+	role := Role{Name: name}
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", name).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		records := make([]Permission, 0, len(permissions))
+		for _, permission := range permissions {
+			records = append(records, Permission{Name: permission})
+		}
+		if len(records) > 0 {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error
+			if err != nil {
+				return err
+			}
+			records = nil
+			if err := tx.Where("name IN ?", permissions).Order("name asc").Find(&records).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&role).Association("Permissions").Replace(records)
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Invalidate("")
+	return &role, nil
+}
+
+// DefineDefaultRoles buat atau samakan semua role di DefaultRoles
+func (a *AccessControl) DefineDefaultRoles(ctx context.Context) error {
+	for name, permissions := range DefaultRoles {
+		if _, err := a.DefineRole(ctx, name, permissions...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthorizeGrant cek actor boleh memberi atau mencabut role di resource.
+// grant global butuh roles.manage global, dan actor harus sudah punya semua
+// permission role itu di resource yang sama supaya tidak bisa menaikkan hak
+// aksesnya sendiri. role dengan PermissionAll cuma bisa diberikan admin.
+func (a *AccessControl) AuthorizeGrant(ctx context.Context, actorID string, roleName string, resource string) error {
+	if resource == "" {
+		if err := a.Authorize(ctx, actorID, PermissionRolesManage, ""); err != nil {
+			return err
+		}
+	}
+
+	var role Role
+	if err := a.db.WithContext(ctx).Preload("Permissions").Take(&role, "name = ?", roleName).Error; err != nil {
+		return notFound(err, "role", roleName)
+	}
+	for _, permission := range role.Permissions {
+		if err := a.Authorize(ctx, actorID, permission.Name, resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Grants semua role user beserta resource nya. user_roles tidak per tenant,
+// user nya dicek dulu supaya role user tenant lain tidak kelihatan.
+func (a *AccessControl) Grants(ctx context.Context, userID string) ([]UserRole, error) {
+	var roles []UserRole
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		return tx.Preload("Role").
+			Where("user_id = ?", userID).
+			Order("role_id asc, resource asc").
+			Find(&roles).Error
+	})
+	return roles, err
+}
+
+// Grant beri role ke user, actorID admin yang melakukan dan ikut dicatat di
+// user_logs milik user. grant yang sudah ada tidak dicatat ulang.
+func (a *AccessControl) Grant(ctx context.Context, actorID string, userID string, roleName string, resource string) error {
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role, err := findRole(tx, roleName)
+		if err != nil {
+			return err
+		}
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&UserRole{
+			UserID:    userID,
+			RoleID:    role.ID,
+			Resource:  resource,
+			GrantedBy: actorID,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Create(&UserLog{UserID: userID, Action: roleLogAction("grant", roleName, resource, actorID)}).Error
+	})
+	if err != nil {
+		return err
+	}
+	a.Invalidate(userID)
+	return nil
+}
+
+// Revoke cabut role dari user di resource yang sama persis dengan waktu
+// Grant. role yang memang tidak dimiliki tidak dianggap error.
+func (a *AccessControl) Revoke(ctx context.Context, actorID string, userID string, roleName string, resource string) error {
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role, err := findRole(tx, roleName)
+		if err != nil {
+			return err
+		}
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ? AND role_id = ? AND resource = ?", userID, role.ID, resource).Delete(&UserRole{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Create(&UserLog{UserID: userID, Action: roleLogAction("revoke", roleName, resource, actorID)}).Error
+	})
+	if err != nil {
+		return err
+	}
+	a.Invalidate(userID)
+	return nil
+}
+
+func findRole(tx *gorm.DB, name string) (*Role, error) {
+	var role Role
+	if err := tx.Take(&role, "name = ?", name).Error; err != nil {
+		return nil, notFound(err, "role", name)
+	}
+	return &role, nil
+}
+
+func roleLogAction(action string, role string, resource string, actorID string) string {
+	if resource == "" {
+		return fmt.Sprintf("%s role %s by %s", action, role, actorID)
+	}
+	return fmt.Sprintf("%s role %s on %s by %s", action, role, resource, actorID)
+}
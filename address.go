@@ -1,16 +1,199 @@
 package belajar_golang_gorm
 
-import "time"
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AddressLineMaxLength  = 200
+	AddressLabelMaxLength = 50
+)
 
 type Address struct {
-	ID        int64     `gorm:"primary_key;column:id;autoIncrement"`
-	UserID    string    `gorm:"column:user_id"`
-	Address   string    `gorm:"column:address"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
-	User      User      `gorm:"foreignKey:user_id;references:id"`
+	ID          int64    `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	TenantID    TenantID `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	UserID      string   `gorm:"column:user_id;size:100;uniqueIndex:idx_addresses_default_shipping,priority:1;uniqueIndex:idx_addresses_default_billing,priority:1" json:"user_id"`
+	Label       string   `gorm:"column:label;size:50" json:"label"`
+	Line1       string   `gorm:"column:line1;size:512;serializer:encrypted" json:"line1"`
+	Line2       string   `gorm:"column:line2;size:512;serializer:encrypted" json:"line2"`
+	City        string   `gorm:"column:city;size:100" json:"city"`
+	Region      string   `gorm:"column:region;size:100" json:"region"`
+	PostalCode  string   `gorm:"column:postal_code;size:20" json:"postal_code"`
+	CountryCode string   `gorm:"column:country_code;size:2" json:"country_code"`
+	// default per user dijaga unique index (user_id, default_x), yang bukan
+	// default disimpan NULL jadi tidak ikut bentrok
+	DefaultShipping DefaultFlag `gorm:"column:default_shipping;uniqueIndex:idx_addresses_default_shipping,priority:2" json:"default_shipping"`
+	DefaultBilling  DefaultFlag `gorm:"column:default_billing;uniqueIndex:idx_addresses_default_billing,priority:2" json:"default_billing"`
+	CreatedAt       time.Time   `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time   `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+	Version         Version     `gorm:"column:version;not null;default:1" json:"version"`
+	User            User        `gorm:"foreignKey:user_id;references:id" json:"-"`
 }
 
 func (a *Address) TableName() string {
 	return "addresses"
 }
+
+// DefaultFlag bool yang disimpan sebagai TRUE atau NULL
+type DefaultFlag bool
+
+func (f DefaultFlag) Value() (driver.Value, error) {
+	if !f {
+		return nil, nil
+	}
+	return true, nil
+}
+
+func (f *DefaultFlag) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*f = false
+	case bool:
+		*f = DefaultFlag(v)
+	case int64:
+		*f = v != 0
+	case []byte:
+		*f = len(v) > 0 && string(v) != "0"
+	default:
+		return fmt.Errorf("unsupported type %T for DefaultFlag", value)
+	}
+	return nil
+}
+
+// AddressDefault jenis alamat default, masing masing paling banyak satu per user
+type AddressDefault string
+
+const (
+	AddressDefaultShipping AddressDefault = "shipping"
+	AddressDefaultBilling  AddressDefault = "billing"
+)
+
+func (d AddressDefault) column() (string, bool) {
+	switch d {
+	case AddressDefaultShipping:
+		return "default_shipping", true
+	case AddressDefaultBilling:
+		return "default_billing", true
+	}
+	return "", false
+}
+
+// postalCodeFormats format kode pos per negara (ISO 3166-1 alpha-2), negara
+// yang tidak ada di sini cukup dicek panjangnya
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"ID": regexp.MustCompile(`^\d{5}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-\d{4}$`),
+	"MY": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+	"PH": regexp.MustCompile(`^\d{4}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"TH": regexp.MustCompile(`^\d{5}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"VN": regexp.MustCompile(`^\d{6}$`),
+}
+
+var countryCodeFormat = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Validate rapikan spasi, country code dan kode pos jadi huruf besar lalu cek
+// field wajib dan format kode pos sesuai negaranya
+func (a *Address) Validate() error {
+	a.Label = strings.TrimSpace(a.Label)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.Region = strings.TrimSpace(a.Region)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+	a.CountryCode = strings.ToUpper(strings.TrimSpace(a.CountryCode))
+
+	fields := map[string]string{}
+	if a.Line1 == "" {
+		fields["line1"] = "must not be empty"
+	} else if utf8.RuneCountInString(a.Line1) > AddressLineMaxLength {
+		fields["line1"] = "must be at most 200 characters"
+	}
+	if utf8.RuneCountInString(a.Line2) > AddressLineMaxLength {
+		fields["line2"] = "must be at most 200 characters"
+	}
+	if utf8.RuneCountInString(a.Label) > AddressLabelMaxLength {
+		fields["label"] = "must be at most 50 characters"
+	}
+	if a.City == "" {
+		fields["city"] = "must not be empty"
+	}
+	if !countryCodeFormat.MatchString(a.CountryCode) {
+		fields["country_code"] = "must be a two letter ISO 3166-1 code"
+	} else if format, ok := postalCodeFormats[a.CountryCode]; ok && !format.MatchString(a.PostalCode) {
+		fields["postal_code"] = "is not a valid postal code for " + a.CountryCode
+	} else if len(a.PostalCode) > 20 {
+		fields["postal_code"] = "must be at most 20 characters"
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// MigrateAddresses tambah kolom alamat terstruktur. isi kolom address lama
+// dipindah ke line1 lalu kolomnya dibuang, kota dan negara alamat lama masih
+// kosong sampai diisi ulang oleh usernya.
+func MigrateAddresses(db *gorm.DB) error {
+	if err := db.Migrator().AutoMigrate(&Address{}); err != nil {
+		return err
+	}
+	if !db.Migrator().HasColumn(&Address{}, "address") {
+		return nil
+	}
+	keyring, err := CurrentKeyring()
+	if err != nil {
+		return err
+	}
+
+	legacyAAD := encryptionAAD("addresses", "address")
+	line1AAD := encryptionAAD("addresses", "line1")
+	var lastID int64
+	for {
+		var rows []encryptedRow
+		err := db.Table("addresses").
+			Select("id", "address AS value").
+			Where("id > ? AND address <> '' AND (line1 IS NULL OR line1 = '')", lastID).
+			Order("id asc").
+			Limit(500).
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			plaintext, err := keyring.Decrypt(row.Value, legacyAAD)
+			if err != nil {
+				return err
+			}
+			line1, err := keyring.Encrypt(plaintext, line1AAD)
+			if err != nil {
+				return err
+			}
+			if err := db.Table("addresses").Where("id = ?", row.ID).Update("line1", line1).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return db.Migrator().DropColumn(&Address{}, "address")
+}
@@ -0,0 +1,192 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"belajar_golang_gorm/filter"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AddressService struct {
+	db *gorm.DB
+}
+
+func NewAddressService(db *gorm.DB) *AddressService {
+	return &AddressService{db: db}
+}
+
+// List query dari filter.Parse dengan AddressFilterSchema, boleh nil
+func (s *AddressService) List(ctx context.Context, userID string, query *filter.Query) ([]Address, error) {
+	var addresses []Address
+	err := s.db.WithContext(ctx).
+		Scopes(query.Scope).
+		Where("user_id = ?", userID).
+		Order("id asc").
+		Find(&addresses).Error
+	return addresses, err
+}
+
+// Get cuma ambil alamat milik userID, alamat user lain dianggap tidak ada
+func (s *AddressService) Get(ctx context.Context, userID string, id int64) (*Address, error) {
+	var address Address
+	err := s.db.WithContext(ctx).Take(&address, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		return nil, notFound(err, "address", strconv.FormatInt(id, 10))
+	}
+	return &address, nil
+}
+
+// AddressUpdate field yang nil tidak diubah. kalau Version diisi update
+// ditolak dengan ErrStaleObject saat alamat sudah diubah orang lain.
+type AddressUpdate struct {
+	Label       *string
+	Line1       *string
+	Line2       *string
+	City        *string
+	Region      *string
+	PostalCode  *string
+	CountryCode *string
+	Version     *Version
+}
+
+// Create kalau alamat baru ditandai default, default lama dilepas di
+// transaksi yang sama
+func (s *AddressService) Create(ctx context.Context, userID string, address *Address) error {
+	address.UserID = userID
+	if err := address.Validate(); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		if address.DefaultShipping {
+			if err := clearDefault(tx, userID, AddressDefaultShipping, 0); err != nil {
+				return err
+			}
+		}
+		if address.DefaultBilling {
+			if err := clearDefault(tx, userID, AddressDefaultBilling, 0); err != nil {
+				return err
+			}
+		}
+		return tx.Omit(clause.Associations).Create(address).Error
+	})
+}
+
+// Update alamat divalidasi ulang secara utuh, kode pos baru bisa jadi tidak
+// cocok dengan negara yang lama
+func (s *AddressService) Update(ctx context.Context, userID string, id int64, update AddressUpdate) (*Address, error) {
+	var address Address
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Take(&address, "id = ? AND user_id = ?", id, userID).Error
+		if err != nil {
+			return notFound(err, "address", strconv.FormatInt(id, 10))
+		}
+
+		columns := []string{}
+		for _, change := range []struct {
+			column string
+			value  *string
+			field  *string
+		}{
+			{"label", update.Label, &address.Label},
+			{"line1", update.Line1, &address.Line1},
+			{"line2", update.Line2, &address.Line2},
+			{"city", update.City, &address.City},
+			{"region", update.Region, &address.Region},
+			{"postal_code", update.PostalCode, &address.PostalCode},
+			{"country_code", update.CountryCode, &address.CountryCode},
+		} {
+			if change.value != nil {
+				*change.field = *change.value
+				columns = append(columns, change.column)
+			}
+		}
+		if len(columns) == 0 {
+			return nil
+		}
+		if err := address.Validate(); err != nil {
+			return err
+		}
+		if update.Version != nil {
+			address.Version = *update.Version
+		}
+
+		// update lewat struct bukan map supaya line1 dan line2 tetap dienkripsi
+		err = tx.Model(&address).Select(columns).Updates(&address).Error
+		if errors.Is(err, ErrStaleObject) {
+			return staleOrNotFound(tx, err, &Address{}, "address", strconv.FormatInt(id, 10))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// SetDefault jadikan alamat id default shipping atau billing, default lama
+// dilepas di transaksi yang sama jadi tidak pernah ada dua default
+func (s *AddressService) SetDefault(ctx context.Context, userID string, id int64, kind AddressDefault) (*Address, error) {
+	column, ok := kind.column()
+	if !ok {
+		return nil, &ValidationError{Fields: map[string]string{"kind": "must be shipping or billing"}}
+	}
+
+	var address Address
+	err := RetryTransaction(ctx, s.db, RetryOptions{Name: "address.set_default"}, func(tx *gorm.DB) error {
+		address = Address{}
+		// semua alamat user di lock supaya pergantian default untuk user yang
+		// sama berjalan satu per satu
+		var ids []int64
+		err := tx.Model(&Address{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Take(&address, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+			return notFound(err, "address", strconv.FormatInt(id, 10))
+		}
+		if err := clearDefault(tx, userID, kind, id); err != nil {
+			return err
+		}
+		return tx.Model(&address).Update(column, DefaultFlag(true)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// Default alamat default user untuk jenis kind
+func (s *AddressService) Default(ctx context.Context, userID string, kind AddressDefault) (*Address, error) {
+	column, ok := kind.column()
+	if !ok {
+		return nil, &ValidationError{Fields: map[string]string{"kind": "must be shipping or billing"}}
+	}
+	var address Address
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Where(column + " IS NOT NULL").Take(&address).Error
+	if err != nil {
+		return nil, notFound(err, "default "+string(kind)+" address", userID)
+	}
+	return &address, nil
+}
+
+// clearDefault lepas default kind milik user, kecuali alamat exceptID
+func clearDefault(tx *gorm.DB, userID string, kind AddressDefault, exceptID int64) error {
+	column, _ := kind.column()
+	return tx.Model(&Address{}).
+		Where("user_id = ? AND id <> ?", userID, exceptID).
+		Where(column+" IS NOT NULL").
+		Update(column, DefaultFlag(false)).Error
+}
+
+func (s *AddressService) Delete(ctx context.Context, userID string, id int64) error {
+	query := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID)
+	return deleteOne(query, &Address{}, "address", strconv.FormatInt(id, 10))
+}
@@ -0,0 +1,77 @@
+// gdpr export semua data user sebagai json ke stdout, atau hapus datanya
+// dengan -erase dan tulis laporan apa saja yang disentuh
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+type emailList []string
+
+func (e *emailList) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *emailList) Set(value string) error {
+	*e = append(*e, value)
+	return nil
+}
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	userID := flag.String("user", "", "id of the user")
+	erase := flag.Bool("erase", false, "erase the user instead of exporting")
+	var emails emailList
+	flag.Var(&emails, "email", "verified email of the user for guest book entries, can be repeated")
+	flag.Parse()
+
+	if *userID == "" {
+		log.Fatal("-user is required")
+	}
+
+	keyring, err := gormapp.ParseKeyring(os.Getenv("ENCRYPTION_KEYS"), os.Getenv("BLIND_INDEX_KEY"))
+	if err != nil {
+		log.Fatalf("ENCRYPTION_KEYS / BLIND_INDEX_KEY: %v", err)
+	}
+	gormapp.SetKeyring(keyring)
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// id user unik di semua tenant, jadi cukup dicari lintas tenant
+	ctx := gormapp.WithoutTenant(context.Background())
+	service := gormapp.NewGDPRService(db)
+	var result interface{}
+	if *erase {
+		result, err = service.EraseUser(ctx, *userID, emails...)
+	} else {
+		result, err = service.ExportUser(ctx, *userID, emails...)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,61 @@
+// orphan-scan cari row yang foreign key nya menunjuk ke user (atau anaknya)
+// yang sudah tidak ada. dengan -fix row yatim dihapus atau foreign key nya
+// dikosongkan sesuai DefaultUserDeleteRules, dengan -migrate foreign key
+// dipasang setelahnya. exit code 1 kalau masih ada row yatim.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	fix := flag.Bool("fix", false, "delete or nullify dangling rows")
+	migrate := flag.Bool("migrate", false, "install foreign keys after a clean scan")
+	flag.Parse()
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := gormapp.ScanOrphans(gormapp.WithoutTenant(context.Background()), db, "users", gormapp.DefaultUserDeleteRules, *fix)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	remaining := int64(0)
+	for _, orphans := range report {
+		if orphans.Count == 0 {
+			continue
+		}
+		log.Printf("%s.%s -> %s (%s): %d dangling, %d removed",
+			orphans.Table, orphans.Column, orphans.Parent, orphans.Policy, orphans.Count, orphans.Removed)
+		remaining += orphans.Count - orphans.Removed
+	}
+	if remaining > 0 {
+		log.Printf("%d dangling rows left, run with -fix to remove them", remaining)
+		os.Exit(1)
+	}
+
+	if *migrate {
+		if err := gormapp.MigrateForeignKeys(db, "users", gormapp.DefaultUserDeleteRules); err != nil {
+			log.Fatal(err)
+		}
+		log.Print("foreign keys installed")
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,58 @@
+// purge-users hapus permanen user yang sudah dinonaktifkan lebih lama dari
+// masa retensi, sekali jalan atau terus menerus sampai di stop dengan ctrl+c
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	retention := flag.Duration("retention", 30*24*time.Hour, "how long deactivated users are kept")
+	loop := flag.Bool("loop", false, "keep running until interrupted")
+	interval := flag.Duration("interval", time.Hour, "delay between runs when -loop is set")
+	flag.Parse()
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// job ini memproses semua tenant sekaligus
+	ctx, stop := signal.NotifyContext(gormapp.WithoutTenant(context.Background()), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	job := gormapp.NewUserRetentionJob(db, gormapp.NewUserService(db))
+	job.Retention = *retention
+	job.Interval = *interval
+
+	if *loop {
+		if err := job.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	purged, err := job.RunOnce(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("purged %d users", purged)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
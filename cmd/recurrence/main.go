@@ -0,0 +1,56 @@
+// recurrence membuat occurrence todo berulang, sekali jalan atau terus
+// menerus sampai di stop dengan ctrl+c
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	loop := flag.Bool("loop", false, "keep running until interrupted")
+	interval := flag.Duration("interval", time.Minute, "delay between runs when -loop is set")
+	horizon := flag.Duration("horizon", 7*24*time.Hour, "how far ahead occurrences are created")
+	flag.Parse()
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// job ini memproses semua tenant sekaligus
+	ctx, stop := signal.NotifyContext(gormapp.WithoutTenant(context.Background()), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheduler := gormapp.NewRecurrenceScheduler(db)
+	scheduler.Interval = *interval
+	scheduler.Horizon = *horizon
+
+	if *loop {
+		scheduler.Run(ctx)
+		return
+	}
+
+	created, err := scheduler.RunOnce(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("created %d todo occurrences", created)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
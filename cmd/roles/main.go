@@ -0,0 +1,74 @@
+// roles kelola role user dari command line, dipakai untuk membuat role
+// bawaan dan memberi admin pertama sebelum endpoint /users/{id}/roles bisa
+// dipakai. setiap grant dan revoke dicatat di user_logs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	seed := flag.Bool("seed", false, "create or update the default roles")
+	userID := flag.String("user", "", "user to grant or revoke")
+	grant := flag.String("grant", "", "role to grant to -user")
+	revoke := flag.String("revoke", "", "role to revoke from -user")
+	resource := flag.String("resource", "", "limit the role to one resource, e.g. product:P001 or product:*")
+	actor := flag.String("actor", envOr("USER", "cli"), "who is making the change, written to user_logs")
+	flag.Parse()
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// id user unik di semua tenant, role juga tidak per tenant
+	ctx := gormapp.WithoutTenant(context.Background())
+	access := gormapp.NewAccessControl(db)
+
+	if *seed {
+		if err := access.DefineDefaultRoles(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *userID == "" {
+		if !*seed {
+			log.Fatal("-user or -seed is required")
+		}
+		return
+	}
+	if *grant != "" {
+		if err := access.Grant(ctx, *actor, *userID, *grant, *resource); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *revoke != "" {
+		if err := access.Revoke(ctx, *actor, *userID, *revoke, *resource); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	roles, err := access.Grants(ctx, *userID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(roles); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
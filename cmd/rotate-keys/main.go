@@ -0,0 +1,64 @@
+// rotate-keys enkripsi ulang kolom terenkripsi dengan kunci terbaru di
+// ENCRYPTION_KEYS, sekali jalan atau terus menerus sampai di stop dengan ctrl+c
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gormapp "belajar_golang_gorm"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	loop := flag.Bool("loop", false, "keep running until interrupted")
+	interval := flag.Duration("interval", time.Hour, "delay between runs when -loop is set")
+	batchSize := flag.Int("batch-size", 500, "rows read per query")
+	flag.Parse()
+
+	keyring, err := gormapp.ParseKeyring(os.Getenv("ENCRYPTION_KEYS"), os.Getenv("BLIND_INDEX_KEY"))
+	if err != nil {
+		log.Fatalf("ENCRYPTION_KEYS / BLIND_INDEX_KEY: %v", err)
+	}
+	gormapp.SetKeyring(keyring)
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// job ini memproses semua tenant sekaligus
+	ctx, stop := signal.NotifyContext(gormapp.WithoutTenant(context.Background()), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	job := gormapp.NewKeyRotationJob(db, keyring)
+	job.Interval = *interval
+	job.BatchSize = *batchSize
+
+	if *loop {
+		if err := job.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	rotated, err := job.RunOnce(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("re-encrypted %d values", rotated)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,92 @@
+// server menjalankan REST API untuk user, wallet, address, product dan
+// guest book. berhenti dengan rapi waktu dapat SIGINT atau SIGTERM.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/handler"
+	"belajar_golang_gorm/pagination"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("HTTP_ADDR", ":8080"), "listen address")
+	dsn := flag.String("dsn", envOr("DATABASE_DSN", gormapp.DefaultDSN), "mysql dsn")
+	cursorSecret := flag.String("cursor-secret", os.Getenv("CURSOR_SECRET"), "secret for signing pagination cursors, random when empty")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 15*time.Second, "time to wait for in-flight requests")
+	flag.Parse()
+
+	db, err := gormapp.OpenDatabase(*dsn, logger.Warn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// kunci enkripsi cuma dari env, flag kelihatan di daftar proses
+	keyring, err := gormapp.ParseKeyring(os.Getenv("ENCRYPTION_KEYS"), os.Getenv("BLIND_INDEX_KEY"))
+	if err != nil {
+		log.Fatalf("ENCRYPTION_KEYS / BLIND_INDEX_KEY: %v", err)
+	}
+	gormapp.SetKeyring(keyring)
+
+	cursors := pagination.NewRandomSigner()
+	if *cursorSecret != "" {
+		cursors = pagination.NewSigner([]byte(*cursorSecret))
+	} else {
+		log.Print("CURSOR_SECRET is not set, pagination cursors will not survive a restart")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler.NewRouter(db, cursors),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", *addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	case <-ctx.Done():
+		log.Print("shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,50 @@
+package belajar_golang_gorm
+
+import (
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const DefaultDSN = "root:admin@tcp(localhost:3306)/belajar_golang_gorm?charset=utf8mb4&parseTime=True&loc=Local"
+
+// OpenDatabase sama seperti OpenConnection di test, tapi return error
+// supaya bisa dipakai dari binary di cmd. error driver sudah diterjemahkan
+// lewat ErrorTranslator. semua query ke model bertenant butuh WithTenant atau
+// WithoutTenant di context, lihat TenantScope.
+func OpenDatabase(dsn string, logLevel logger.LogLevel) (*gorm.DB, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(ErrorTranslator{}); err != nil {
+		return nil, err
+	}
+	// sebelum OptimisticLock supaya WHERE tenant_id ikut disimpan guardVersion
+	if err := db.Use(TenantScope{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(OptimisticLock{}); err != nil {
+		return nil, err
+	}
+	// user_roles punya kolom resource, Preload("Roles") harus pakai model UserRole
+	if err := db.SetupJoinTable(&User{}, "Roles", &UserRole{}); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetConnMaxLifetime(30 * time.Minute)
+	sqlDB.SetConnMaxIdleTime(5 * time.Minute)
+
+	return db, nil
+}
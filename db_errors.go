@@ -0,0 +1,158 @@
+package belajar_golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicate   = errors.New("duplicate key")
+	ErrForeignKey  = errors.New("foreign key violation")
+	ErrNotFound    = gorm.ErrRecordNotFound
+	ErrDeadlock    = errors.New("deadlock")
+	ErrLockTimeout = errors.New("lock wait timeout")
+)
+
+// nomor error mysql yang diterjemahkan
+const (
+	mysqlErrLockWaitTimeout   = 1205
+	mysqlErrDeadlock          = 1213
+	mysqlErrRowIsReferenced   = 1451
+	mysqlErrNoReferencedRow   = 1452
+	mysqlErrDupEntry          = 1062
+	mysqlErrDupEntryWithKey   = 1586
+	mysqlErrRowIsReferencedV1 = 1217
+	mysqlErrNoReferencedRowV1 = 1216
+	mysqlErrLockNowait        = 3572
+)
+
+// DuplicateError insert atau update yang melanggar primary key / unique index.
+// Constraint nama index di mysql, di sqlite berisi tabel.kolom.
+type DuplicateError struct {
+	Constraint string
+	Value      string
+	Err        error
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("duplicate value %q for %s", e.Value, e.Constraint)
+	}
+	return "duplicate value for " + e.Constraint
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate || target == gorm.ErrDuplicatedKey
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return e.Err
+}
+
+// ForeignKeyError Constraint kosong kalau database tidak menyebutkan namanya
+// (sqlite tidak pernah menyebutkan)
+type ForeignKeyError struct {
+	Constraint string
+	Err        error
+}
+
+func (e *ForeignKeyError) Error() string {
+	if e.Constraint == "" {
+		return "foreign key violation"
+	}
+	return "foreign key violation on " + e.Constraint
+}
+
+func (e *ForeignKeyError) Is(target error) bool {
+	return target == ErrForeignKey || target == gorm.ErrForeignKeyViolated
+}
+
+func (e *ForeignKeyError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	mysqlDuplicatePattern  = regexp.MustCompile(`Duplicate entry '(.*)' for key '([^']+)'`)
+	mysqlConstraintPattern = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+)
+
+// TranslateError ubah error driver mysql atau sqlite jadi error di atas,
+// error yang tidak dikenal dikembalikan apa adanya. error asli tetap bisa
+// diambil dengan errors.As.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var duplicate *DuplicateError
+	var foreignKey *ForeignKeyError
+	if errors.As(err, &duplicate) || errors.As(err, &foreignKey) {
+		return err
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return translateMySQLError(mysqlErr, err)
+	}
+	if translated, ok := translateSQLiteError(err); ok {
+		return translated
+	}
+	return err
+}
+
+func translateMySQLError(mysqlErr *mysql.MySQLError, err error) error {
+	switch mysqlErr.Number {
+	case mysqlErrDupEntry, mysqlErrDupEntryWithKey:
+		duplicate := &DuplicateError{Err: err}
+		if match := mysqlDuplicatePattern.FindStringSubmatch(mysqlErr.Message); match != nil {
+			duplicate.Value = match[1]
+			duplicate.Constraint = match[2]
+		}
+		return duplicate
+	case mysqlErrRowIsReferenced, mysqlErrNoReferencedRow, mysqlErrRowIsReferencedV1, mysqlErrNoReferencedRowV1:
+		foreignKey := &ForeignKeyError{Err: err}
+		if match := mysqlConstraintPattern.FindStringSubmatch(mysqlErr.Message); match != nil {
+			foreignKey.Constraint = match[1]
+		}
+		return foreignKey
+	case mysqlErrDeadlock:
+		return fmt.Errorf("%w: %w", ErrDeadlock, err)
+	case mysqlErrLockWaitTimeout, mysqlErrLockNowait:
+		return fmt.Errorf("%w: %w", ErrLockTimeout, err)
+	}
+	return err
+}
+
+// ErrorTranslator plugin gorm yang menjalankan TranslateError di setiap
+// query, jadi error dari db.Create dan lainnya sudah berupa error di atas
+type ErrorTranslator struct{}
+
+func (ErrorTranslator) Name() string {
+	return "error_translator"
+}
+
+func (ErrorTranslator) Initialize(db *gorm.DB) error {
+	translate := func(tx *gorm.DB) {
+		if tx.Error != nil {
+			tx.Error = TranslateError(tx.Error)
+		}
+	}
+
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("*").Register("error_translator:create", translate),
+		callbacks.Query().After("*").Register("error_translator:query", translate),
+		callbacks.Update().After("*").Register("error_translator:update", translate),
+		callbacks.Delete().After("*").Register("error_translator:delete", translate),
+		callbacks.Row().After("*").Register("error_translator:row", translate),
+		callbacks.Raw().After("*").Register("error_translator:raw", translate),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package belajar_golang_gorm
+
+// driver sqlite butuh cgo, tanpa cgo cuma error mysql yang diterjemahkan
+func translateSQLiteError(err error) (error, bool) {
+	return nil, false
+}
@@ -0,0 +1,38 @@
+//go:build cgo
+
+package belajar_golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// translateSQLiteError pakai extended code sqlite, ok false kalau err
+// bukan error sqlite atau kodenya tidak diterjemahkan
+func translateSQLiteError(err error) (error, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil, false
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		// pesannya "UNIQUE constraint failed: users.email"
+		_, columns, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+		return &DuplicateError{Constraint: columns, Err: err}, true
+	case sqlite3.ErrConstraintForeignKey:
+		return &ForeignKeyError{Err: err}, true
+	case sqlite3.ErrBusySnapshot:
+		// sqlite tidak punya deadlock, snapshot yang basi juga harus diulang
+		// dari awal transaksinya
+		return fmt.Errorf("%w: %w", ErrDeadlock, err), true
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return fmt.Errorf("%w: %w", ErrLockTimeout, err), true
+	}
+	return nil, false
+}
@@ -0,0 +1,259 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrDeleteRestricted = errors.New("delete restricted")
+
+// DeletePolicy apa yang terjadi dengan row anak waktu induknya dihapus
+type DeletePolicy string
+
+const (
+	// DeleteCascade row anak ikut dihapus, termasuk anak dari anaknya
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteRestrict induk tidak boleh dihapus selama masih punya anak
+	DeleteRestrict DeletePolicy = "restrict"
+	// DeleteNullify foreign key anak dikosongkan, kolomnya harus nullable
+	DeleteNullify DeletePolicy = "nullify"
+	// DeleteSoftCascade row anak di soft delete, tabelnya harus punya
+	// deleted_at. dipakai waktu induknya juga di soft delete, kalau induknya
+	// benar benar dihapus foreign key ikut menghapus anaknya seperti cascade.
+	DeleteSoftCascade DeletePolicy = "soft_cascade"
+)
+
+// onDelete aksi ON DELETE untuk foreign key di database
+func (p DeletePolicy) onDelete() string {
+	switch p {
+	case DeleteCascade, DeleteSoftCascade:
+		return "CASCADE"
+	case DeleteNullify:
+		return "SET NULL"
+	default:
+		return "RESTRICT"
+	}
+}
+
+// DeleteRule satu tabel anak yang menunjuk ke id tabel induk lewat Column.
+// Children aturan untuk tabel di bawahnya, cuma dipakai kalau Policy cascade.
+type DeleteRule struct {
+	Table    string
+	Column   string
+	Policy   DeletePolicy
+	Children []DeleteRule
+}
+
+// DefaultUserDeleteRules aturan waktu user dihapus permanen. wallet sengaja
+// restrict supaya saldo tidak hilang diam diam, wallet harus ditutup dulu.
+var DefaultUserDeleteRules = []DeleteRule{
+	{Table: "wallets", Column: "user_id", Policy: DeleteRestrict},
+	{Table: "addresses", Column: "user_id", Policy: DeleteCascade},
+	{Table: "user_like_products", Column: "user_id", Policy: DeleteCascade},
+	{Table: "todos", Column: "user_id", Policy: DeleteCascade, Children: todoDeleteRules},
+	{Table: "todo_lists", Column: "owner_id", Policy: DeleteCascade, Children: []DeleteRule{
+		{Table: "todo_list_members", Column: "list_id", Policy: DeleteCascade},
+		{Table: "todo_list_activities", Column: "list_id", Policy: DeleteCascade},
+		{Table: "todos", Column: "list_id", Policy: DeleteNullify},
+	}},
+	{Table: "todo_list_members", Column: "user_id", Policy: DeleteCascade},
+	{Table: "todo_list_activities", Column: "user_id", Policy: DeleteNullify},
+	{Table: "user_roles", Column: "user_id", Policy: DeleteCascade},
+	// log audit tetap disimpan
+	{Table: "user_logs", Column: "user_id", Policy: DeleteNullify},
+}
+
+// DefaultUserSoftDeleteRules aturan waktu user di soft delete, data lain tetap
+// ada supaya akun bisa diaktifkan lagi
+var DefaultUserSoftDeleteRules = []DeleteRule{
+	{Table: "todos", Column: "user_id", Policy: DeleteSoftCascade},
+}
+
+var todoDeleteRules = []DeleteRule{
+	{Table: "todo_tags", Column: "todo_id", Policy: DeleteCascade},
+	{Table: "todo_recurrences", Column: "template_id", Policy: DeleteCascade},
+	{Table: "todo_list_activities", Column: "todo_id", Policy: DeleteNullify},
+}
+
+// RestrictedError induk masih punya row anak dengan aturan restrict
+type RestrictedError struct {
+	Resource string
+	ID       string
+	Table    string
+	Count    int64
+}
+
+func (e *RestrictedError) Error() string {
+	return fmt.Sprintf("%s %s still has %d row(s) in %s", e.Resource, e.ID, e.Count, e.Table)
+}
+
+func (e *RestrictedError) Is(target error) bool {
+	return target == ErrDeleteRestricted
+}
+
+// applyDeleteRules jalankan rules untuk induk yang id nya dipilih parentIDs
+// (subquery SELECT id), harus dipanggil di transaksi sebelum induknya dihapus.
+// deletedAt dipakai untuk soft_cascade, samakan dengan deleted_at induknya.
+func applyDeleteRules(tx *gorm.DB, rules []DeleteRule, parentIDs *gorm.DB, deletedAt time.Time, resource string, id string) error {
+	for _, rule := range rules {
+		children := func() *gorm.DB {
+			return tx.Table(rule.Table).Where(rule.Column+" IN (?)", parentIDs)
+		}
+
+		switch rule.Policy {
+		case DeleteRestrict:
+			var count int64
+			if err := children().Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return &RestrictedError{Resource: resource, ID: id, Table: rule.Table, Count: count}
+			}
+		case DeleteCascade:
+			if err := applyDeleteRules(tx, rule.Children, children().Select("id"), deletedAt, resource, id); err != nil {
+				return err
+			}
+			if err := children().Delete(map[string]interface{}{}).Error; err != nil {
+				return err
+			}
+		case DeleteNullify:
+			if err := children().Update(rule.Column, nil).Error; err != nil {
+				return err
+			}
+		case DeleteSoftCascade:
+			if err := children().Where("deleted_at IS NULL").Update("deleted_at", deletedAt).Error; err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown delete policy %q for %s.%s", rule.Policy, rule.Table, rule.Column)
+		}
+	}
+	return nil
+}
+
+// restoreDeleteRules kebalikan soft_cascade, cuma row yang di soft delete
+// bersamaan dengan induknya yang dikembalikan. yang sudah dibuang sendiri
+// sebelumnya tetap terhapus.
+func restoreDeleteRules(tx *gorm.DB, rules []DeleteRule, parentIDs *gorm.DB, deletedAt time.Time) error {
+	for _, rule := range rules {
+		if rule.Policy != DeleteSoftCascade {
+			continue
+		}
+		err := tx.Table(rule.Table).
+			Where(rule.Column+" IN (?)", parentIDs).
+			Where("deleted_at = ?", deletedAt).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateForeignKeys pasang foreign key sesuai rules di mysql, constraint
+// lama di kolom yang sama diganti. jalankan orphan-scan dulu, foreign key
+// tidak bisa dipasang selama masih ada row yatim.
+func MigrateForeignKeys(db *gorm.DB, parentTable string, rules []DeleteRule) error {
+	if db.Dialector.Name() != "mysql" {
+		return fmt.Errorf("foreign key migration is not supported for %s", db.Dialector.Name())
+	}
+	for _, rule := range rules {
+		if err := migrateForeignKey(db, parentTable, rule); err != nil {
+			return err
+		}
+		if err := MigrateForeignKeys(db, rule.Table, rule.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type foreignKeyInfo struct {
+	Name       string
+	DeleteRule string
+}
+
+func migrateForeignKey(db *gorm.DB, parentTable string, rule DeleteRule) error {
+	name := "fk_" + rule.Table + "_" + rule.Column
+	onDelete := rule.Policy.onDelete()
+
+	var existing []foreignKeyInfo
+	err := db.Raw(`SELECT k.CONSTRAINT_NAME AS name, r.DELETE_RULE AS delete_rule
+		FROM information_schema.KEY_COLUMN_USAGE k
+		JOIN information_schema.REFERENTIAL_CONSTRAINTS r
+			ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+		WHERE k.TABLE_SCHEMA = DATABASE() AND k.TABLE_NAME = ? AND k.COLUMN_NAME = ? AND k.REFERENCED_TABLE_NAME = ?`,
+		rule.Table, rule.Column, parentTable).Scan(&existing).Error
+	if err != nil {
+		return err
+	}
+
+	for _, constraint := range existing {
+		if constraint.Name == name && constraint.DeleteRule == onDelete {
+			return nil
+		}
+	}
+	for _, constraint := range existing {
+		if err := db.Exec("ALTER TABLE " + rule.Table + " DROP FOREIGN KEY " + constraint.Name).Error; err != nil {
+			return err
+		}
+	}
+	return db.Exec("ALTER TABLE " + rule.Table + " ADD CONSTRAINT " + name +
+		" FOREIGN KEY (" + rule.Column + ") REFERENCES " + parentTable + " (id) ON DELETE " + onDelete).Error
+}
+
+// Orphans jumlah row anak yang foreign key nya menunjuk ke induk yang
+// sudah tidak ada
+type Orphans struct {
+	Table  string       `json:"table"`
+	Column string       `json:"column"`
+	Parent string       `json:"parent"`
+	Policy DeletePolicy `json:"policy"`
+	Count  int64        `json:"count"`
+	// Removed row yang dihapus atau dikosongkan foreign key nya
+	Removed int64 `json:"removed"`
+}
+
+// ScanOrphans cari row yatim di semua tabel di rules. kalau fix true,
+// row yatim dengan aturan nullify dikosongkan foreign key nya dan sisanya
+// dihapus. induk diproses lebih dulu, jadi anak dari row yatim yang baru
+// dihapus ikut ketemu.
+func ScanOrphans(ctx context.Context, db *gorm.DB, parentTable string, rules []DeleteRule, fix bool) ([]Orphans, error) {
+	var result []Orphans
+	for _, rule := range rules {
+		orphans := Orphans{Table: rule.Table, Column: rule.Column, Parent: parentTable, Policy: rule.Policy}
+		dangling := func() *gorm.DB {
+			return db.WithContext(ctx).Table(rule.Table).
+				Where(rule.Column+" IS NOT NULL").
+				Where(rule.Column+" NOT IN (?)", db.Table(parentTable).Select("id"))
+		}
+
+		if err := dangling().Count(&orphans.Count).Error; err != nil {
+			return result, err
+		}
+		if fix && orphans.Count > 0 {
+			var removed *gorm.DB
+			if rule.Policy == DeleteNullify {
+				removed = dangling().Update(rule.Column, nil)
+			} else {
+				removed = dangling().Delete(map[string]interface{}{})
+			}
+			if removed.Error != nil {
+				return result, removed.Error
+			}
+			orphans.Removed = removed.RowsAffected
+		}
+		result = append(result, orphans)
+
+		children, err := ScanOrphans(ctx, db, rule.Table, rule.Children, fix)
+		result = append(result, children...)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrNoKeyring      = errors.New("encryption keyring is not configured")
+	ErrUnknownKey     = errors.New("unknown encryption key")
+	ErrInvalidKeyring = errors.New("invalid encryption keyring")
+	ErrInvalidCipher  = errors.New("invalid encrypted value")
+)
+
+const (
+	encryptedPrefix    = "enc:v1:"
+	encryptionKeyBytes = 32
+)
+
+// Keyring kunci AES-256 per key id. nilai baru selalu dienkripsi dengan
+// kunci current, kunci lama tetap disimpan supaya data lama masih bisa
+// dibaca sampai KeyRotationJob selesai.
+type Keyring struct {
+	current  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring tiap kunci harus 32 byte, indexKey kunci HMAC untuk blind index
+// dan tidak boleh diganti selama kolom indexnya masih dipakai
+func NewKeyring(current string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: current key %q is missing", ErrInvalidKeyring, current)
+	}
+	if len(indexKey) < 16 {
+		return nil, fmt.Errorf("%w: blind index key must be at least 16 bytes", ErrInvalidKeyring)
+	}
+
+	keyring := &Keyring{current: current, keys: map[string]cipher.AEAD{}, indexKey: indexKey}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%w: key id %q", ErrInvalidKeyring, id)
+		}
+		if len(key) != encryptionKeyBytes {
+			return nil, fmt.Errorf("%w: key %q must be %d bytes", ErrInvalidKeyring, id, encryptionKeyBytes)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[id] = aead
+	}
+	return keyring, nil
+}
+
+// ParseKeyring baca keyring dari format env "id:base64,id:base64", kunci
+// pertama jadi current. indexKey juga base64.
+func ParseKeyring(spec string, indexKey string) (*Keyring, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, fmt.Errorf("%w: no keys given", ErrInvalidKeyring)
+	}
+	keys := map[string][]byte{}
+	current := ""
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %q is not id:base64", ErrInvalidKeyring, entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %v", ErrInvalidKeyring, id, err)
+		}
+		if current == "" {
+			current = id
+		}
+		keys[id] = key
+	}
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: blind index key: %v", ErrInvalidKeyring, err)
+	}
+	return NewKeyring(current, keys, index)
+}
+
+// Encrypt hasilnya "enc:v1:<key id>:<base64 nonce+ciphertext>". aad diikat ke
+// tabel dan kolom supaya nilai tidak bisa dipindah ke kolom lain.
+func (k *Keyring) Encrypt(plaintext string, aad string) (string, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return encryptedPrefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt nilai tanpa prefix dianggap data lama yang belum dienkripsi
+func (k *Keyring) Decrypt(value string, aad string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", ErrInvalidCipher
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCipher
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return "", ErrInvalidCipher
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation true untuk nilai yang belum dienkripsi atau masih pakai
+// kunci lama
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	return !strings.HasPrefix(value, encryptedPrefix) || id != k.current
+}
+
+// BlindIndex HMAC dari nilai, sama untuk nilai yang sama jadi bisa dicari
+// dengan WHERE kolom_index = ?. normalisasi (lowercase dll) tugas pemanggil.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var currentKeyring atomic.Pointer[Keyring]
+
+// SetKeyring pasang keyring untuk serializer encrypted, harus dipanggil
+// sebelum membaca atau menulis kolom terenkripsi
+func SetKeyring(keyring *Keyring) {
+	currentKeyring.Store(keyring)
+}
+
+func CurrentKeyring() (*Keyring, error) {
+	keyring := currentKeyring.Load()
+	if keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	return keyring, nil
+}
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// EncryptedSerializer serializer gorm untuk field string,
+// pakai dengan tag `gorm:"serializer:encrypted"`
+type EncryptedSerializer struct{}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("%w: unsupported type %T for %s", ErrInvalidCipher, dbValue, field.Name)
+	}
+
+	if value != "" {
+		keyring, err := CurrentKeyring()
+		if err != nil {
+			return err
+		}
+		value, err = keyring.Decrypt(value, encryptionAAD(field.Schema.Table, field.DBName))
+		if err != nil {
+			return fmt.Errorf("decrypt %s.%s: %w", field.Schema.Table, field.DBName, err)
+		}
+	}
+	return field.Set(ctx, dst, value)
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a string", ErrInvalidCipher, field.Name)
+	}
+	// string kosong tidak dienkripsi, cukup ketahuan kosong
+	if value == "" {
+		return "", nil
+	}
+	keyring, err := CurrentKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Encrypt(value, encryptionAAD(field.Schema.Table, field.DBName))
+}
+
+func encryptionAAD(table string, column string) string {
+	return table + "." + column
+}
@@ -0,0 +1,47 @@
+package belajar_golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// NotFoundError dipakai juga waktu data ada tapi bukan milik user,
+// supaya keberadaan data orang lain tidak bocor
+type NotFoundError struct {
+	Resource string
+	ID       string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Resource, e.ID)
+}
+
+// biar errors.Is(err, gorm.ErrRecordNotFound) tetap jalan
+func (e *NotFoundError) Is(target error) bool {
+	return target == gorm.ErrRecordNotFound
+}
+
+// ValidationError berisi pesan error per field input
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, name+" "+e.Fields[name])
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
@@ -0,0 +1,246 @@
+// Package filter ubah query string seperti
+//
+//	?filter[first_name][like]=Jo&sort=-created_at&fields=id,first_name
+//
+// jadi clause gorm. nama kolom hanya diambil dari Schema, nilai dari user
+// selalu jadi parameter, jadi tidak ada input yang masuk mentah ke SQL.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Operator string
+
+const (
+	Eq   Operator = "eq"
+	Ne   Operator = "ne"
+	Gt   Operator = "gt"
+	Gte  Operator = "gte"
+	Lt   Operator = "lt"
+	Lte  Operator = "lte"
+	Like Operator = "like"
+	In   Operator = "in"
+	Null Operator = "null"
+)
+
+// operator yang boleh kalau Field.Operators kosong
+var DefaultOperators = []Operator{Eq, Ne, In}
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Error menunjuk parameter mana yang salah
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+// Field satu field yang boleh dipakai dari query string. Unfilterable
+// untuk field yang cuma boleh dipilih di fields, misalnya kolom terenkripsi,
+// tanpa itu Operators kosong berarti DefaultOperators.
+type Field struct {
+	Column       string
+	Operators    []Operator
+	Unfilterable bool
+	Sortable     bool
+	Selectable   bool
+}
+
+// Schema whitelist field per model, key-nya nama field di query string
+type Schema struct {
+	Fields      map[string]Field
+	DefaultSort []Sort
+	// kolom yang selalu ikut di select, misal primary key
+	AlwaysSelect []string
+}
+
+type Condition struct {
+	Column   string
+	Operator Operator
+	Values   []string
+}
+
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+type Query struct {
+	Conditions []Condition
+	Sorts      []Sort
+	Columns    []string
+}
+
+var filterParam = regexp.MustCompile(`^filter\[([a-zA-Z0-9_]+)\](?:\[([a-z]+)\])?$`)
+
+// Parse baca filter[...], sort dan fields dari values. parameter lain
+// (misal limit atau cursor) diabaikan.
+func Parse(values url.Values, schema Schema) (*Query, error) {
+	query := &Query{}
+
+	// urutkan supaya SQL yang dihasilkan selalu sama untuk query yang sama
+	params := make([]string, 0, len(values))
+	for param := range values {
+		if strings.HasPrefix(param, "filter") {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		list := values[param]
+		match := filterParam.FindStringSubmatch(param)
+		if match == nil {
+			return nil, &Error{Param: param, Message: "expected filter[field] or filter[field][operator]"}
+		}
+		field, ok := schema.Fields[match[1]]
+		if !ok {
+			return nil, &Error{Param: param, Message: "unknown field " + match[1]}
+		}
+		operator := Eq
+		if match[2] != "" {
+			operator = Operator(match[2])
+		}
+		if !field.allows(operator) {
+			return nil, &Error{Param: param, Message: fmt.Sprintf("operator %s is not allowed for %s", operator, match[1])}
+		}
+
+		for _, value := range list {
+			condition := Condition{Column: field.Column, Operator: operator, Values: []string{value}}
+			switch operator {
+			case In:
+				condition.Values = strings.Split(value, ",")
+			case Null:
+				if value != "true" && value != "false" {
+					return nil, &Error{Param: param, Message: "null expects true or false"}
+				}
+			}
+			query.Conditions = append(query.Conditions, condition)
+		}
+	}
+
+	if text := values.Get("sort"); text != "" {
+		for _, name := range strings.Split(text, ",") {
+			order := Sort{}
+			if strings.HasPrefix(name, "-") {
+				order.Desc = true
+				name = name[1:]
+			}
+			field, ok := schema.Fields[name]
+			if !ok || !field.Sortable {
+				return nil, &Error{Param: "sort", Message: "cannot sort by " + name}
+			}
+			order.Column = field.Column
+			query.Sorts = append(query.Sorts, order)
+		}
+	} else {
+		query.Sorts = append(query.Sorts, schema.DefaultSort...)
+	}
+
+	if text := values.Get("fields"); text != "" {
+		seen := map[string]bool{}
+		for _, column := range schema.AlwaysSelect {
+			seen[column] = true
+			query.Columns = append(query.Columns, column)
+		}
+		for _, name := range strings.Split(text, ",") {
+			field, ok := schema.Fields[name]
+			if !ok || !field.Selectable {
+				return nil, &Error{Param: "fields", Message: "cannot select " + name}
+			}
+			if !seen[field.Column] {
+				seen[field.Column] = true
+				query.Columns = append(query.Columns, field.Column)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+func (f Field) allows(operator Operator) bool {
+	if f.Unfilterable {
+		return false
+	}
+	operators := f.Operators
+	if len(operators) == 0 {
+		operators = DefaultOperators
+	}
+	for _, allowed := range operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope pasang hasil Parse ke query gorm, dipakai lewat db.Scopes(q.Scope).
+// query nil tidak mengubah apa-apa.
+func (q *Query) Scope(db *gorm.DB) *gorm.DB {
+	if q == nil {
+		return db
+	}
+
+	for _, condition := range q.Conditions {
+		db = db.Where(condition.expression())
+	}
+	for _, order := range q.Sorts {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: order.Column}, Desc: order.Desc})
+	}
+	if len(q.Columns) > 0 {
+		db = db.Select(q.Columns)
+	}
+	return db
+}
+
+func (c Condition) expression() clause.Expression {
+	column := clause.Column{Name: c.Column}
+	value := c.Values[0]
+
+	switch c.Operator {
+	case Ne:
+		return clause.Neq{Column: column, Value: value}
+	case Gt:
+		return clause.Gt{Column: column, Value: value}
+	case Gte:
+		return clause.Gte{Column: column, Value: value}
+	case Lt:
+		return clause.Lt{Column: column, Value: value}
+	case Lte:
+		return clause.Lte{Column: column, Value: value}
+	case Like:
+		// % dan _ dari user dianggap huruf biasa, pakai ! sebagai escape
+		// karena backslash beda arti di mysql dan sqlite
+		escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, "%" + escaped + "%"}}
+	case In:
+		values := make([]interface{}, len(c.Values))
+		for i, v := range c.Values {
+			values[i] = v
+		}
+		return clause.IN{Column: column, Values: values}
+	case Null:
+		if value == "true" {
+			return clause.Eq{Column: column, Value: nil}
+		}
+		return clause.Neq{Column: column, Value: nil}
+	default:
+		return clause.Eq{Column: column, Value: value}
+	}
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID        string
+	FirstName string
+	Password  string
+}
+
+var schema = Schema{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Sortable: true, Selectable: true},
+		"first_name": {Column: "first_name", Operators: []Operator{Eq, Like}, Sortable: true, Selectable: true},
+		"email":      {Column: "email", Unfilterable: true, Selectable: true},
+	},
+	AlwaysSelect: []string{"id"},
+}
+
+// dry run, tidak butuh koneksi ke mysql
+func dryRun(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	assert.Nil(t, err)
+	return db
+}
+
+func TestParse(t *testing.T) {
+	values, _ := url.ParseQuery("filter[first_name][like]=Jo%25&filter[id][in]=1,2&sort=-first_name,id&fields=first_name")
+	query, err := Parse(values, schema)
+	assert.Nil(t, err)
+
+	statement := dryRun(t).Scopes(query.Scope).Find(&[]user{}).Statement
+	assert.Equal(t,
+		"SELECT `id`,`first_name` FROM `users` WHERE `first_name` LIKE ? ESCAPE '!' AND `id` IN (?,?) ORDER BY `first_name` DESC,`id`",
+		statement.SQL.String())
+	assert.Equal(t, []interface{}{"%Jo!%%", "1", "2"}, statement.Vars)
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	for _, raw := range []string{
+		"filter[password]=rahasia",
+		"filter[first_name][gt]=A",
+		"filter[email]=eko@example.com",
+		"filter[email][ne]=eko@example.com",
+		"filter[first_name)--]=x",
+		"sort=password",
+		"fields=password",
+	} {
+		values, _ := url.ParseQuery(raw)
+		_, err := Parse(values, schema)
+		assert.ErrorIs(t, err, ErrInvalidQuery, raw)
+	}
+}
+
+func TestNilQueryScope(t *testing.T) {
+	var query *Query
+	statement := dryRun(t).Scopes(query.Scope).Find(&[]user{}).Statement
+	assert.Equal(t, "SELECT * FROM `users`", statement.SQL.String())
+}
@@ -0,0 +1,283 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GDPRService melayani permintaan data subject, export dan hapus data user.
+// user tidak punya kolom email, jadi email untuk mencari entri guest book
+// dikirim oleh pemanggil setelah identitas pemohon diverifikasi.
+type GDPRService struct {
+	db  *gorm.DB
+	Now func() time.Time
+}
+
+func NewGDPRService(db *gorm.DB) *GDPRService {
+	return &GDPRService{db: db, Now: time.Now}
+}
+
+// UserExport semua data milik satu user, siap di encode ke json
+type UserExport struct {
+	ExportedAt       time.Time   `json:"exported_at"`
+	User             User        `json:"user"`
+	Wallet           *Wallet     `json:"wallet"`
+	Addresses        []Address   `json:"addresses"`
+	LikedProducts    []Product   `json:"liked_products"`
+	Todos            []Todo      `json:"todos"`
+	GuestBookEntries []GuestBook `json:"guest_book_entries"`
+	Logs             []UserLog   `json:"logs"`
+}
+
+// ErasureReport jumlah row per tabel yang dihapus, dianonimkan atau sengaja
+// disimpan karena wajib disimpan (saldo wallet dan log audit)
+type ErasureReport struct {
+	UserID     string           `json:"user_id"`
+	ErasedAt   time.Time        `json:"erased_at"`
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized"`
+	Retained   map[string]int64 `json:"retained"`
+}
+
+// ExportUser baca semua data dalam satu transaksi supaya isinya konsisten.
+// todo yang sudah di trash ikut, password tidak ikut.
+func (s *GDPRService) ExportUser(ctx context.Context, userID string, emails ...string) (*UserExport, error) {
+	export := &UserExport{ExportedAt: s.Now()}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// user yang sudah dinonaktifkan tetap boleh minta datanya
+		err := tx.Unscoped().Take(&export.User, "id = ?", userID).Error
+		if err != nil {
+			return notFound(err, "user", userID)
+		}
+
+		var wallets []Wallet
+		if err := tx.Where("user_id = ?", userID).Limit(1).Find(&wallets).Error; err != nil {
+			return err
+		}
+		if len(wallets) > 0 {
+			export.Wallet = &wallets[0]
+		}
+
+		if export.Addresses, err = NewAddressRepository(tx).FindByUserID(ctx, userID); err != nil {
+			return err
+		}
+		if export.LikedProducts, err = NewProductRepository(tx).FindLikedBy(ctx, userID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Order("id asc").Find(&export.Todos).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id asc").Find(&export.Logs).Error; err != nil {
+			return err
+		}
+
+		indexes, err := guestBookEmailIndexes(emails)
+		if err != nil {
+			return err
+		}
+		export.GuestBookEntries = []GuestBook{}
+		if len(indexes) > 0 {
+			return tx.Where("email_index IN ?", indexes).Order("id asc").Find(&export.GuestBookEntries).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// EraseUser hapus data user dalam satu transaksi. row user tidak dihapus
+// tapi dianonimkan, karena wallet dan user_logs yang wajib disimpan masih
+// menunjuk ke id user. todo list milik user dihapus, todo anggota lain di
+// list itu tetap ada tapi keluar dari list.
+func (s *GDPRService) EraseUser(ctx context.Context, userID string, emails ...string) (*ErasureReport, error) {
+	report := &ErasureReport{
+		UserID:     userID,
+		ErasedAt:   s.Now(),
+		Deleted:    map[string]int64{},
+		Anonymized: map[string]int64{},
+		Retained:   map[string]int64{},
+	}
+	indexes, err := guestBookEmailIndexes(emails)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx.Unscoped(), &User{}, "user", userID); err != nil {
+			return err
+		}
+
+		steps := []func(tx *gorm.DB, userID string, report *ErasureReport) error{
+			eraseTodos,
+			eraseTodoLists,
+			eraseAddressesAndLikes,
+			eraseRoles,
+		}
+		for _, step := range steps {
+			if err := step(tx, userID, report); err != nil {
+				return err
+			}
+		}
+
+		if len(indexes) > 0 {
+			result := tx.Where("email_index IN ?", indexes).Delete(&GuestBook{})
+			if result.Error != nil {
+				return result.Error
+			}
+			report.Deleted["guest_books"] = result.RowsAffected
+		}
+
+		result := tx.Unscoped().Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":    "",
+			"first_name":  "Deleted",
+			"middle_name": "",
+			"last_name":   "User",
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		report.Anonymized["users"] = result.RowsAffected
+
+		for table, model := range map[string]interface{}{"wallets": &Wallet{}, "user_logs": &UserLog{}} {
+			var count int64
+			if err := tx.Model(model).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return err
+			}
+			report.Retained[table] = count
+		}
+		return tx.Create(&UserLog{UserID: userID, Action: "erase user"}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// eraseTodos hard delete semua todo user termasuk yang di trash, relasi
+// tag dan aturan berulangnya dihapus dulu seperti di Purge
+func eraseTodos(tx *gorm.DB, userID string, report *ErasureReport) error {
+	var ids []uint
+	if err := tx.Unscoped().Model(&Todo{}).Where("user_id = ?", userID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	result := tx.Exec("DELETE FROM todo_tags WHERE todo_id IN ?", ids)
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["todo_tags"] = result.RowsAffected
+
+	result = tx.Where("template_id IN ?", ids).Delete(&TodoRecurrence{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["todo_recurrences"] = result.RowsAffected
+
+	result = tx.Unscoped().Where("id IN ?", ids).Delete(&Todo{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["todos"] = result.RowsAffected
+	return nil
+}
+
+func eraseTodoLists(tx *gorm.DB, userID string, report *ErasureReport) error {
+	var listIDs []int64
+	if err := tx.Model(&TodoList{}).Where("owner_id = ?", userID).Pluck("id", &listIDs).Error; err != nil {
+		return err
+	}
+
+	if len(listIDs) > 0 {
+		result := tx.Unscoped().Model(&Todo{}).Where("list_id IN ?", listIDs).Update("list_id", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		report.Anonymized["todos"] = result.RowsAffected
+	}
+
+	// activity dan keanggotaan user sendiri, ditambah semua isi list miliknya
+	ownedOrOwnList := func(db *gorm.DB) *gorm.DB {
+		if len(listIDs) == 0 {
+			return db.Where("user_id = ?", userID)
+		}
+		return db.Where("user_id = ? OR list_id IN ?", userID, listIDs)
+	}
+	result := tx.Scopes(ownedOrOwnList).Delete(&TodoListActivity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["todo_list_activities"] = result.RowsAffected
+
+	result = tx.Scopes(ownedOrOwnList).Delete(&TodoListMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["todo_list_members"] = result.RowsAffected
+
+	result = tx.Model(&TodoListMember{}).Where("invited_by = ?", userID).Update("invited_by", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Anonymized["todo_list_members"] = result.RowsAffected
+
+	if len(listIDs) > 0 {
+		result = tx.Where("id IN ?", listIDs).Delete(&TodoList{})
+		if result.Error != nil {
+			return result.Error
+		}
+		report.Deleted["todo_lists"] = result.RowsAffected
+	}
+	return nil
+}
+
+func eraseAddressesAndLikes(tx *gorm.DB, userID string, report *ErasureReport) error {
+	result := tx.Where("user_id = ?", userID).Delete(&Address{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["addresses"] = result.RowsAffected
+
+	result = tx.Exec("DELETE FROM user_like_products WHERE user_id = ?", userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["user_like_products"] = result.RowsAffected
+	return nil
+}
+
+// eraseRoles cabut semua role user, grant yang dia berikan ke user lain
+// tetap berlaku tapi granted_by nya dikosongkan. cache AccessControl di
+// instance yang sedang jalan baru kosong setelah CacheTTL lewat.
+func eraseRoles(tx *gorm.DB, userID string, report *ErasureReport) error {
+	result := tx.Where("user_id = ?", userID).Delete(&UserRole{})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Deleted["user_roles"] = result.RowsAffected
+
+	result = tx.Model(&UserRole{}).Where("granted_by = ?", userID).Update("granted_by", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Anonymized["user_roles"] = result.RowsAffected
+	return nil
+}
+
+func guestBookEmailIndexes(emails []string) ([]string, error) {
+	indexes := make([]string, 0, len(emails))
+	for _, email := range emails {
+		index, err := GuestBookEmailIndex(email)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
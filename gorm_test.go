@@ -3,12 +3,15 @@ package belajar_golang_gorm
 //belajar lagi golang gorm
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -640,11 +643,11 @@ func TestUserAndAddresses(t *testing.T) {
 		Addresses: []Address{
 			{
 				UserID: "2",
-				Address: "Jl. Raya No. 2",
+				Line1: "Jl. Raya No. 2",
 			},
 			{
 				UserID: "2",
-				Address: "Jl. Raya No. 51",
+				Line1: "Jl. Raya No. 51",
 			},
 		},
 	}
@@ -901,7 +904,25 @@ func TestScopes(t *testing.T) {
 }
 
 func TestMigrator(t *testing.T) {
-	err := db.Migrator().AutoMigrate(&GuestBook{})
+	err := MigrateAddresses(db)
+	assert.Nil(t, err)
+
+	err = MigrateUserSoftDelete(db)
+	assert.Nil(t, err)
+
+	err = MigrateUserSearchName(db)
+	assert.Nil(t, err)
+
+	err = db.Migrator().AutoMigrate(&GuestBook{}, &TodoList{}, &TodoListMember{}, &TodoListActivity{}, &Todo{}, &Tag{}, &TodoRecurrence{}, &RateLimitCounter{})
+	assert.Nil(t, err)
+
+	err = MigrateTodoSearch(db)
+	assert.Nil(t, err)
+
+	err = MigrateRoles(db)
+	assert.Nil(t, err)
+
+	err = MigrateTenants(db, "default")
 	assert.Nil(t, err)
 }
 
@@ -919,3 +940,1259 @@ func TestHook(t *testing.T) {
 
 	fmt.Println(user.ID)
 }
+
+func TestTodoTrashRestore(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	todo := Todo{
+		UserID:      "1",
+		Title:       "Todo trash",
+		Description: "Isi todo trash",
+	}
+	err := db.Create(&todo).Error
+	assert.Nil(t, err)
+
+	err = service.Trash(ctx, "1", todo.ID)
+	assert.Nil(t, err)
+
+	trash, err := service.ListTrash(ctx, "1")
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(trash))
+
+	err = service.Restore(ctx, "1", todo.ID)
+	assert.Nil(t, err)
+
+	var restored Todo
+	err = db.First(&restored, "id = ?", todo.ID).Error
+	assert.Nil(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+}
+
+func TestTodoPurge(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	todo := Todo{
+		UserID: "1",
+		Title:  "Todo purge",
+	}
+	err := db.Create(&todo).Error
+	assert.Nil(t, err)
+
+	err = service.Trash(ctx, "1", todo.ID)
+	assert.Nil(t, err)
+
+	purged, err := service.Purge(ctx, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.NotEqual(t, int64(0), purged)
+
+	var count int64
+	err = db.Unscoped().Model(&Todo{}).Where("id = ?", todo.ID).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestTodoStatusTransition(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	due := time.Now().Add(-time.Hour)
+	todo := Todo{
+		UserID:   "1",
+		Title:    "Todo status",
+		Priority: TodoPriorityHigh,
+		DueAt:    &due,
+	}
+	err := db.Create(&todo).Error
+	assert.Nil(t, err)
+	assert.Equal(t, TodoStatusOpen, todo.Status)
+
+	var overdue []Todo
+	err = db.Scopes(OverdueTodos).Find(&overdue).Error
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(overdue))
+
+	updated, err := service.SetStatus(ctx, "1", todo.ID, TodoStatusDone)
+	assert.Nil(t, err)
+	assert.NotNil(t, updated.CompletedAt)
+
+	_, err = service.SetStatus(ctx, "1", todo.ID, TodoStatusInProgress)
+	assert.ErrorIs(t, err, ErrInvalidTodoStatus)
+
+	var completed []Todo
+	err = db.Scopes(CompletedThisWeekTodos).Find(&completed).Error
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(completed))
+
+	var dueToday []Todo
+	err = db.Scopes(DueTodayTodos).Find(&dueToday).Error
+	assert.Nil(t, err)
+}
+
+func TestTodoOwnership(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	todo := Todo{
+		Title:       "Todo milik user 1",
+		Description: "Isi todo",
+	}
+	err := service.Create(ctx, "1", &todo)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", todo.UserID)
+
+	_, err = service.Get(ctx, "2", todo.ID)
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = service.Update(ctx, "2", todo.ID, map[string]interface{}{"title": "Diambil user 2"})
+	assert.ErrorAs(t, err, &notFound)
+
+	err = service.Trash(ctx, "2", todo.ID)
+	assert.ErrorAs(t, err, &notFound)
+
+	updated, err := service.Update(ctx, "1", todo.ID, map[string]interface{}{"title": "Todo diubah"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Todo diubah", updated.Title)
+}
+
+func TestPreloadTodos(t *testing.T) {
+	var user User
+	err := db.Preload("Todos").First(&user, "id = ?", "1").Error
+	assert.Nil(t, err)
+	fmt.Println(user.Todos)
+
+	var todos []Todo
+	err = db.Preload("User").Where("user_id = ?", "1").Find(&todos).Error
+	assert.Nil(t, err)
+	for _, todo := range todos {
+		assert.Equal(t, "1", todo.User.ID)
+	}
+}
+
+func TestTodoTags(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	todo := Todo{
+		Title: "Laporan mingguan",
+	}
+	err := service.Create(ctx, "1", &todo)
+	assert.Nil(t, err)
+
+	err = service.AddTags(ctx, "1", todo.ID, "Work", "report", "work")
+	assert.Nil(t, err)
+
+	todos, err := service.ListByTags(ctx, "1", []string{"work", "urgent"}, false)
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(todos))
+
+	todos, err = service.ListByTags(ctx, "1", []string{"work", "report"}, true)
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(todos))
+
+	todos, err = service.ListByTags(ctx, "1", []string{"work", "urgent"}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(todos))
+
+	err = service.RemoveTags(ctx, "1", todo.ID, "report")
+	assert.Nil(t, err)
+}
+
+func TestTodoSearch(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	err := service.Create(ctx, "1", &Todo{
+		Title:       "Kirim invoice bulanan",
+		Description: "Invoice untuk client ayam goreng",
+	})
+	assert.Nil(t, err)
+
+	results, err := service.Search(ctx, "1", "invoice")
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(results))
+	for i := 1; i < len(results); i++ {
+		assert.GreaterOrEqual(t, results[i-1].Score, results[i].Score)
+	}
+}
+
+func TestRecurrenceNextOccurrence(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.Local)
+
+	monthly := TodoRecurrence{Frequency: RecurrenceMonthly, DayOfMonth: 31, StartsAt: start}
+	next, ok := monthly.NextOccurrence(start.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 0, time.Local), next)
+
+	weekly := TodoRecurrence{Frequency: RecurrenceWeekly, Weekdays: []time.Weekday{time.Monday, time.Friday}, StartsAt: start}
+	next, ok = weekly.NextOccurrence(start)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, time.February, 2, 9, 0, 0, 0, time.Local), next)
+}
+
+func TestRecurrenceScheduler(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	template := Todo{
+		Title: "Weekly report",
+	}
+	err := service.Create(ctx, "1", &template)
+	assert.Nil(t, err)
+
+	recurrence := TodoRecurrence{
+		TemplateID: template.ID,
+		Frequency:  RecurrenceDaily,
+		StartsAt:   time.Now(),
+		MaxCount:   3,
+	}
+	err = db.Create(&recurrence).Error
+	assert.Nil(t, err)
+
+	scheduler := NewRecurrenceScheduler(db)
+	created, err := scheduler.RunOnce(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, created)
+
+	// jalan kedua tidak boleh bikin duplikat
+	created, err = scheduler.RunOnce(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, created)
+
+	var count int64
+	err = db.Model(&Todo{}).Where("recurrence_id = ?", recurrence.ID).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestRecurrenceSchedulerRerunWindow(t *testing.T) {
+	ctx := context.Background()
+	service := NewTodoService(db)
+
+	template := Todo{Title: "Daily standup"}
+	err := service.Create(ctx, "1", &template)
+	assert.Nil(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	recurrence := TodoRecurrence{
+		TemplateID: template.ID,
+		Frequency:  RecurrenceDaily,
+		StartsAt:   now,
+		MaxCount:   5,
+	}
+	err = db.Create(&recurrence).Error
+	assert.Nil(t, err)
+
+	scheduler := NewRecurrenceScheduler(db)
+	scheduler.Now = func() time.Time { return now }
+	scheduler.Horizon = 60 * time.Hour
+	_, err = scheduler.RunOnce(ctx)
+	assert.Nil(t, err)
+
+	// jendela yang sama dijalankan lagi, occurrence yang sudah ada tidak
+	// boleh menghabiskan jatah MaxCount
+	err = db.Model(&recurrence).Update("next_at", nil).Error
+	assert.Nil(t, err)
+	_, err = scheduler.RunOnce(ctx)
+	assert.Nil(t, err)
+
+	var stored TodoRecurrence
+	err = db.Take(&stored, "id = ?", recurrence.ID).Error
+	assert.Nil(t, err)
+	assert.Equal(t, 3, stored.GeneratedCount)
+	assert.Nil(t, stored.FinishedAt)
+
+	scheduler.Horizon = 10 * 24 * time.Hour
+	_, err = scheduler.RunOnce(ctx)
+	assert.Nil(t, err)
+
+	var count int64
+	err = db.Model(&Todo{}).Where("recurrence_id = ?", recurrence.ID).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), count)
+}
+
+func TestRecurrenceSchedulerKeepsRunning(t *testing.T) {
+	// port yang tidak dipakai, semua query gagal dengan connection refused
+	broken, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "root:admin@tcp(localhost:1)/belajar_golang_gorm",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failures := make(chan error, 3)
+
+	scheduler := NewRecurrenceScheduler(broken)
+	scheduler.Interval = 10 * time.Millisecond
+	scheduler.OnError = func(err error) {
+		select {
+		case failures <- err:
+		default:
+			cancel()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop after ctx was cancelled")
+	}
+	// Run tidak berhenti di error pertama
+	assert.Equal(t, 3, len(failures))
+}
+
+func TestSharedTodoList(t *testing.T) {
+	ctx := context.Background()
+	lists := NewTodoListService(db)
+	service := NewTodoService(db)
+
+	list, err := lists.CreateList(ctx, "1", "Belanja bulanan")
+	assert.Nil(t, err)
+
+	err = lists.Invite(ctx, "1", list.ID, "2", ListRoleViewer)
+	assert.Nil(t, err)
+	err = lists.Invite(ctx, "1", list.ID, "3", ListRoleEditor)
+	assert.Nil(t, err)
+
+	// belum accept berarti list belum kelihatan
+	_, err = lists.Todos(ctx, "2", list.ID)
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	err = lists.AcceptInvitation(ctx, "2", list.ID)
+	assert.Nil(t, err)
+	err = lists.AcceptInvitation(ctx, "3", list.ID)
+	assert.Nil(t, err)
+	err = lists.DeclineInvitation(ctx, "3", list.ID)
+	assert.ErrorIs(t, err, ErrInvitationNotFound)
+
+	// user 3 tidak boleh undang orang karena bukan owner
+	err = lists.Invite(ctx, "3", list.ID, "4", ListRoleViewer)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	todo := Todo{
+		Title:  "Beli beras",
+		ListID: &list.ID,
+	}
+	err = service.Create(ctx, "3", &todo)
+	assert.Nil(t, err)
+
+	// viewer bisa lihat tapi tidak bisa ubah
+	_, err = service.Get(ctx, "2", todo.ID)
+	assert.Nil(t, err)
+	err = service.Trash(ctx, "2", todo.ID)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	// owner list boleh ubah todo yang dibuat editor
+	_, err = service.SetStatus(ctx, "1", todo.ID, TodoStatusDone)
+	assert.Nil(t, err)
+
+	activities, err := lists.Activity(ctx, "2", list.ID, 10)
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(activities))
+}
+
+func TestGuestBookPagination(t *testing.T) {
+	ctx := context.Background()
+	service := NewGuestBookService(db)
+
+	for i := 0; i < 3; i++ {
+		err := service.Create(ctx, &GuestBook{
+			Name:    "Tamu " + strconv.Itoa(i),
+			Email:   "tamu" + strconv.Itoa(i) + "@example.com",
+			Message: "Pesan " + strconv.Itoa(i),
+		})
+		assert.Nil(t, err)
+	}
+
+	first, err := service.List(ctx, "", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(first.Items))
+	assert.NotEqual(t, "", first.NextCursor)
+
+	second, err := service.List(ctx, first.NextCursor, 2)
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(second.Items))
+	assert.NotEqual(t, first.Items[1].ID, second.Items[0].ID)
+
+	back, err := service.List(ctx, second.PrevCursor, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, first.Items[0].ID, back.Items[0].ID)
+	assert.Equal(t, first.Items[1].ID, back.Items[1].ID)
+
+	err = service.Create(ctx, &GuestBook{Name: "X", Email: "salah", Message: ""})
+	var validation *ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+type fixedSpamScorer float64
+
+func (s fixedSpamScorer) Score(ctx context.Context, entry *GuestBook) (float64, error) {
+	return float64(s), nil
+}
+
+func TestRuleSpamScorer(t *testing.T) {
+	scorer := NewRuleSpamScorer(db)
+
+	score, err := scorer.Score(context.Background(), &GuestBook{
+		Name:    "Promo",
+		Email:   "promo@example.com",
+		Message: "Slot gacor di https://a.example dan https://b.example",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, score)
+
+	score, err = scorer.Score(context.Background(), &GuestBook{
+		Name:    "Eko",
+		Email:   "eko.bersih@example.com",
+		Message: "Terima kasih",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, score)
+}
+
+func TestGuestBookModeration(t *testing.T) {
+	ctx := context.Background()
+	service := NewGuestBookService(db)
+	service.Scorer = fixedSpamScorer(0.5)
+
+	entry := GuestBook{
+		Name:    "Tamu ragu",
+		Email:   "ragu@example.com",
+		Message: "Pesan yang perlu dicek",
+	}
+	err := service.Create(ctx, &entry)
+	assert.Nil(t, err)
+	assert.Equal(t, ModerationPending, entry.Status)
+
+	queue, err := service.PendingQueue(ctx, "", 100)
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(queue.Items))
+
+	approved, err := service.Approve(ctx, "1", entry.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, ModerationApproved, approved.Status)
+
+	// entry yang sudah dimoderasi tidak bisa dimoderasi lagi
+	_, err = service.Reject(ctx, "1", entry.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	service.Scorer = fixedSpamScorer(0.9)
+	spam := GuestBook{
+		Name:    "Spammer",
+		Email:   "spam@example.com",
+		Message: "Beli sekarang",
+	}
+	err = service.Create(ctx, &spam)
+	assert.Nil(t, err)
+	assert.Equal(t, ModerationRejected, spam.Status)
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewRateLimiter(db)
+	now := time.Date(2024, time.March, 1, 10, 30, 0, 0, time.Local)
+	limiter.Now = func() time.Time { return now }
+
+	rule := RateLimitRule{
+		Key:    "test:" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Limit:  2,
+		Window: time.Hour,
+	}
+	assert.Nil(t, limiter.Allow(ctx, rule))
+	assert.Nil(t, limiter.Allow(ctx, rule))
+
+	err := limiter.Allow(ctx, rule)
+	var limited *RateLimitError
+	assert.ErrorAs(t, err, &limited)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, time.Hour, limited.RetryAfter)
+
+	// hit yang ditolak tidak ikut dihitung
+	now = now.Add(limited.RetryAfter)
+	assert.Nil(t, limiter.Allow(ctx, rule))
+}
+
+func TestGuestBookRateLimit(t *testing.T) {
+	ctx := context.Background()
+	service := NewGuestBookService(db)
+	service.EmailLimit = 1
+
+	email := "limit" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@example.com"
+	err := service.Create(ctx, &GuestBook{Name: "Eko", Email: email, Message: "Pesan pertama"})
+	assert.Nil(t, err)
+
+	err = service.Create(ctx, &GuestBook{Name: "Eko", Email: email, Message: "Pesan kedua"})
+	var limited *RateLimitError
+	assert.ErrorAs(t, err, &limited)
+	assert.Greater(t, limited.RetryAfter, time.Duration(0))
+
+	// jatah tiap tenant terpisah
+	tenantA := WithTenant(ctx, "limit-a")
+	tenantB := WithTenant(ctx, "limit-b")
+	err = service.Create(tenantA, &GuestBook{Name: "Eko", Email: email, Message: "Pesan tenant A"})
+	assert.Nil(t, err)
+	err = service.Create(tenantA, &GuestBook{Name: "Eko", Email: email, Message: "Pesan tenant A lagi"})
+	assert.ErrorIs(t, err, ErrRateLimited)
+	err = service.Create(tenantB, &GuestBook{Name: "Eko", Email: email, Message: "Pesan tenant B"})
+	assert.Nil(t, err)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repository := NewProductRepository(db)
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	products := []Product{
+		{ID: "repo-a-" + suffix, Name: "Produk A", Price: 1000},
+		{ID: "repo-b-" + suffix, Name: "Produk B", Price: 2000},
+	}
+	err := repository.CreateInBatches(ctx, products, 10)
+	assert.Nil(t, err)
+
+	product, err := repository.FindByID(ctx, products[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Produk A", product.Name)
+
+	product.Name = "Produk A Baru"
+	product.Price = 0
+	err = repository.Update(ctx, product, "name")
+	assert.Nil(t, err)
+
+	product, err = repository.FindByID(ctx, products[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Produk A Baru", product.Name)
+	assert.Equal(t, int64(1000), product.Price)
+
+	err = repository.Update(ctx, &Product{ID: "repo-missing-" + suffix, Name: "x"}, "name")
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	err = repository.Delete(ctx, products[1].ID)
+	assert.Nil(t, err)
+
+	exists, err := repository.Exists(ctx, products[1].ID)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		count, err := repository.WithTx(tx).Count(ctx, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id LIKE ?", "repo-%-"+suffix)
+		})
+		assert.Equal(t, int64(1), count)
+		return err
+	})
+	assert.Nil(t, err)
+}
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	id := "uow-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	committed := false
+	err := NewUnitOfWork(db).Do(ctx, func(tx *UnitOfWorkTx) error {
+		err := tx.Users().Create(ctx, &User{ID: id, Password: "rahasia", Name: Name{FirstName: "User UOW"}})
+		if err != nil {
+			return err
+		}
+		tx.AfterCommit(func(ctx context.Context) {
+			committed = true
+		})
+
+		// savepoint gagal, wallet kedua tidak ikut tersimpan
+		err = tx.Savepoint(func(tx *UnitOfWorkTx) error {
+			if err := tx.Wallets().Create(ctx, &Wallet{ID: id + "-x", UserID: id}); err != nil {
+				return err
+			}
+			return fmt.Errorf("batal")
+		})
+		assert.NotNil(t, err)
+		assert.False(t, committed)
+
+		return tx.Wallets().Create(ctx, &Wallet{ID: id, UserID: id, Balance: 1000000})
+	})
+	assert.Nil(t, err)
+	assert.True(t, committed)
+
+	wallets, err := NewWalletRepository(db).Count(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", id)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), wallets)
+
+	assert.Panics(t, func() {
+		_ = NewUnitOfWork(db).Do(ctx, func(tx *UnitOfWorkTx) error {
+			_ = tx.Products().Create(ctx, &Product{ID: id, Name: "Produk Panic"})
+			panic("gagal")
+		})
+	})
+	exists, err := NewProductRepository(db).Exists(ctx, id)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestTranslateError(t *testing.T) {
+	err := TranslateError(&mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry '11' for key 'users.PRIMARY'"})
+	var duplicate *DuplicateError
+	assert.ErrorAs(t, err, &duplicate)
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.Equal(t, "users.PRIMARY", duplicate.Constraint)
+	assert.Equal(t, "11", duplicate.Value)
+
+	err = TranslateError(&mysqldriver.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`belajar_golang_gorm`.`addresses`, CONSTRAINT `fk_users_addresses` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"})
+	var foreignKey *ForeignKeyError
+	assert.ErrorAs(t, err, &foreignKey)
+	assert.Equal(t, "fk_users_addresses", foreignKey.Constraint)
+
+	assert.ErrorIs(t, TranslateError(&mysqldriver.MySQLError{Number: 1213}), ErrDeadlock)
+	assert.ErrorIs(t, TranslateError(&mysqldriver.MySQLError{Number: 1205}), ErrLockTimeout)
+	assert.ErrorIs(t, TranslateError(gorm.ErrRecordNotFound), ErrNotFound)
+
+	// error asli dari mysql tetap bisa diambil
+	var mysqlErr *mysqldriver.MySQLError
+	assert.ErrorAs(t, TranslateError(&mysqldriver.MySQLError{Number: 1213}), &mysqlErr)
+}
+
+func TestErrorTranslatorPlugin(t *testing.T) {
+	translated := OpenConnection()
+	err := translated.Use(ErrorTranslator{})
+	assert.Nil(t, err)
+
+	// user 11 sudah dibuat di TestTransactionSuccses
+	err = translated.Create(&User{ID: "11", Password: "password"}).Error
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	err = translated.Create(&Address{UserID: "user-tidak-ada", Line1: "Jalan"}).Error
+	assert.ErrorIs(t, err, ErrForeignKey)
+}
+
+func TestRetryTransaction(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewRetryMetrics()
+	options := RetryOptions{Name: "test.retry", Metrics: metrics, BaseDelay: time.Millisecond}
+
+	// dua kali deadlock lalu berhasil
+	attempts := 0
+	err := RetryTransaction(ctx, db, options, func(tx *gorm.DB) error {
+		attempts++
+		if attempts < 3 {
+			return &mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		return tx.Model(&Wallet{}).Where("id = ?", "1").Update("balance", gorm.Expr("balance")).Error
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// error yang bukan transient tidak diulang
+	attempts = 0
+	err = RetryTransaction(ctx, db, options, func(tx *gorm.DB) error {
+		attempts++
+		return ErrInsufficientBalance
+	})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = RetryTransaction(ctx, db, options, func(tx *gorm.DB) error {
+		attempts++
+		return &mysqldriver.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	})
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.Equal(t, DefaultRetryAttempts, attempts)
+
+	assert.Equal(t, []RetryStats{{Name: "test.retry", Calls: 3, Retries: 4, Exhausted: 1}}, metrics.Snapshot())
+}
+
+func TestOptimisticLock(t *testing.T) {
+	locked := OpenConnection()
+	err := locked.Use(OptimisticLock{})
+	assert.Nil(t, err)
+
+	id := "lock-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err = locked.Create(&Product{ID: id, Name: "Produk Lock", Price: 1000}).Error
+	assert.Nil(t, err)
+
+	var first, second Product
+	err = locked.Take(&first, "id = ?", id).Error
+	assert.Nil(t, err)
+	err = locked.Take(&second, "id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Equal(t, Version(1), first.Version)
+
+	first.Name = "Produk Lock Pertama"
+	err = locked.Save(&first).Error
+	assert.Nil(t, err)
+	assert.Equal(t, Version(2), first.Version)
+
+	// second masih version 1
+	second.Name = "Produk Lock Kedua"
+	err = locked.Save(&second).Error
+	assert.ErrorIs(t, err, ErrStaleObject)
+
+	// update dengan map seperti TestSelectedColumns
+	err = locked.Model(&second).Updates(map[string]interface{}{
+		"price": 2000,
+	}).Error
+	assert.ErrorIs(t, err, ErrStaleObject)
+
+	err = locked.Model(&Product{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"price":   2000,
+		"version": first.Version,
+	}).Error
+	assert.Nil(t, err)
+
+	var product Product
+	err = locked.Take(&product, "id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "Produk Lock Pertama", product.Name)
+	assert.Equal(t, int64(2000), product.Price)
+	assert.Equal(t, Version(3), product.Version)
+}
+
+// kunci yang sama dengan yang dipakai di handler test
+var testKeys = map[string][]byte{"test": bytes.Repeat([]byte("k"), 32)}
+
+func init() {
+	keyring, err := NewKeyring("test", testKeys, []byte("blind-index-test-key"))
+	if err != nil {
+		panic(err)
+	}
+	SetKeyring(keyring)
+}
+
+func TestEncryptedSerializer(t *testing.T) {
+	address := Address{UserID: "1", Line1: "Jalan Rahasia No. 1"}
+	err := db.Create(&address).Error
+	assert.Nil(t, err)
+
+	var stored string
+	err = db.Raw("SELECT line1 FROM addresses WHERE id = ?", address.ID).Scan(&stored).Error
+	assert.Nil(t, err)
+	assert.NotContains(t, stored, "Rahasia")
+
+	var result Address
+	err = db.Take(&result, "id = ?", address.ID).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "Jalan Rahasia No. 1", result.Line1)
+
+	email := "Blind" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@example.com"
+	err = db.Create(&GuestBook{Name: "Eko", Email: email, Message: "Halo"}).Error
+	assert.Nil(t, err)
+
+	entries, err := NewGuestBookRepository(db).FindByEmail(context.Background(), strings.ToUpper(email))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, email, entries[0].Email)
+}
+
+func TestKeyRotationJob(t *testing.T) {
+	ctx := context.Background()
+	address := Address{UserID: "1", Line1: "Jalan Rotasi"}
+	err := db.Create(&address).Error
+	assert.Nil(t, err)
+
+	rotatedKeys := map[string][]byte{"test": testKeys["test"], "baru": bytes.Repeat([]byte("b"), 32)}
+	keyring, err := NewKeyring("baru", rotatedKeys, []byte("blind-index-test-key"))
+	assert.Nil(t, err)
+	SetKeyring(keyring)
+	// setelah test semua data dikembalikan ke kunci test, supaya test
+	// berikutnya masih bisa membaca
+	defer func() {
+		back, _ := NewKeyring("test", rotatedKeys, []byte("blind-index-test-key"))
+		SetKeyring(back)
+		_, err := NewKeyRotationJob(db, back).RunOnce(ctx)
+		assert.Nil(t, err)
+
+		original, _ := NewKeyring("test", testKeys, []byte("blind-index-test-key"))
+		SetKeyring(original)
+	}()
+
+	job := NewKeyRotationJob(db, keyring)
+	rotated, err := job.RunOnce(ctx)
+	assert.Nil(t, err)
+	assert.Greater(t, rotated, 0)
+
+	rotated, err = job.RunOnce(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rotated)
+
+	// kunci lama sudah tidak dibutuhkan
+	onlyNew, err := NewKeyring("baru", map[string][]byte{"baru": rotatedKeys["baru"]}, []byte("blind-index-test-key"))
+	assert.Nil(t, err)
+	SetKeyring(onlyNew)
+
+	var result Address
+	err = db.Take(&result, "id = ?", address.ID).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "Jalan Rotasi", result.Line1)
+}
+
+func TestGDPRExportErase(t *testing.T) {
+	ctx := context.Background()
+	id := "gdpr-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	email := id + "@example.com"
+	err := db.Create(&User{ID: id, Password: "rahasia", Name: Name{FirstName: "Data", LastName: "Subject"}}).Error
+	assert.Nil(t, err)
+	err = db.Create(&Wallet{ID: id, UserID: id, Balance: 1000}).Error
+	assert.Nil(t, err)
+	err = db.Create(&Address{UserID: id, Line1: "Jalan GDPR"}).Error
+	assert.Nil(t, err)
+
+	todos := NewTodoService(db)
+	active := Todo{Title: "Aktif"}
+	trashed := Todo{Title: "Dibuang"}
+	assert.Nil(t, todos.Create(ctx, id, &active))
+	assert.Nil(t, todos.Create(ctx, id, &trashed))
+	assert.Nil(t, todos.Trash(ctx, id, trashed.ID))
+
+	guestBooks := NewGuestBookService(db)
+	guestBooks.Scorer = fixedSpamScorer(0)
+	err = guestBooks.Create(ctx, &GuestBook{Name: "Data Subject", Email: email, Message: "Halo"})
+	assert.Nil(t, err)
+
+	// role yang dimiliki dan yang pernah dia berikan ke user lain
+	access := NewAccessControl(db)
+	_, err = access.DefineRole(ctx, "test_gdpr", PermissionGuestBookModerate)
+	assert.Nil(t, err)
+	assert.Nil(t, access.Grant(ctx, "admin", id, "test_gdpr", ""))
+	other := id + "-other"
+	err = db.Create(&User{ID: other, Password: "rahasia", Name: Name{FirstName: "Lain"}}).Error
+	assert.Nil(t, err)
+	assert.Nil(t, access.Grant(ctx, id, other, "test_gdpr", ""))
+
+	service := NewGDPRService(db)
+	export, err := service.ExportUser(ctx, id, strings.ToUpper(email))
+	assert.Nil(t, err)
+	assert.Equal(t, "Data", export.User.Name.FirstName)
+	assert.NotNil(t, export.Wallet)
+	assert.Len(t, export.Addresses, 1)
+	assert.Len(t, export.Todos, 2)
+	assert.Len(t, export.GuestBookEntries, 1)
+	assert.NotEmpty(t, export.Logs)
+
+	report, err := service.EraseUser(ctx, id, email)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), report.Deleted["todos"])
+	assert.Equal(t, int64(1), report.Deleted["addresses"])
+	assert.Equal(t, int64(1), report.Deleted["guest_books"])
+	assert.Equal(t, int64(1), report.Anonymized["users"])
+	assert.Equal(t, int64(1), report.Retained["wallets"])
+	assert.Equal(t, int64(1), report.Deleted["user_roles"])
+	assert.Equal(t, int64(1), report.Anonymized["user_roles"])
+
+	var grantedBy []string
+	err = db.Model(&UserRole{}).Where("user_id = ?", other).Pluck("granted_by", &grantedBy).Error
+	assert.Nil(t, err)
+	assert.Equal(t, []string{""}, grantedBy)
+
+	var user User
+	err = db.Take(&user, "id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "Deleted", user.Name.FirstName)
+	assert.Equal(t, "", user.Password)
+
+	var wallet Wallet
+	err = db.Take(&wallet, "user_id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), wallet.Balance)
+
+	_, err = service.EraseUser(ctx, "tidak-ada")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestAddressDefaults(t *testing.T) {
+	ctx := context.Background()
+	userID := "address-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := db.Create(&User{ID: userID, Password: "rahasia", Name: Name{FirstName: "Alamat"}}).Error
+	assert.Nil(t, err)
+
+	service := NewAddressService(db)
+	invalid := Address{Line1: "Jl. Salah", City: "Jakarta", PostalCode: "123", CountryCode: "ID"}
+	err = service.Create(ctx, userID, &invalid)
+	var validation *ValidationError
+	assert.ErrorAs(t, err, &validation)
+	assert.Contains(t, validation.Fields, "postal_code")
+
+	home := Address{Label: "Rumah", Line1: "Jl. Raya No. 1", City: "Jakarta", PostalCode: "12345", CountryCode: "id", DefaultShipping: true, DefaultBilling: true}
+	err = service.Create(ctx, userID, &home)
+	assert.Nil(t, err)
+	assert.Equal(t, "ID", home.CountryCode)
+
+	office := Address{Label: "Kantor", Line1: "Jl. Kantor No. 2", City: "Bandung", PostalCode: "40111", CountryCode: "ID", DefaultShipping: true}
+	err = service.Create(ctx, userID, &office)
+	assert.Nil(t, err)
+
+	shipping, err := service.Default(ctx, userID, AddressDefaultShipping)
+	assert.Nil(t, err)
+	assert.Equal(t, office.ID, shipping.ID)
+	billing, err := service.Default(ctx, userID, AddressDefaultBilling)
+	assert.Nil(t, err)
+	assert.Equal(t, home.ID, billing.ID)
+
+	_, err = service.SetDefault(ctx, userID, home.ID, AddressDefaultShipping)
+	assert.Nil(t, err)
+	shipping, err = service.Default(ctx, userID, AddressDefaultShipping)
+	assert.Nil(t, err)
+	assert.Equal(t, home.ID, shipping.ID)
+
+	var defaults int64
+	err = db.Model(&Address{}).Where("user_id = ? AND default_shipping IS NOT NULL", userID).Count(&defaults).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), defaults)
+
+	// unique index menolak default kedua walaupun lewat service
+	err = db.Model(&Address{}).Where("id = ?", office.ID).Update("default_shipping", DefaultFlag(true)).Error
+	assert.NotNil(t, err)
+
+	country := "NL"
+	_, err = service.Update(ctx, userID, office.ID, AddressUpdate{CountryCode: &country})
+	assert.ErrorAs(t, err, &validation)
+
+	postalCode := "1012 ab"
+	updated, err := service.Update(ctx, userID, office.ID, AddressUpdate{CountryCode: &country, PostalCode: &postalCode})
+	assert.Nil(t, err)
+	assert.Equal(t, "1012 AB", updated.PostalCode)
+}
+
+func TestUserDeletePolicies(t *testing.T) {
+	ctx := context.Background()
+	id := "delete-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := db.Create(&User{ID: id, Password: "rahasia", Name: Name{FirstName: "Hapus"}}).Error
+	assert.Nil(t, err)
+	err = db.Create(&Address{UserID: id, Line1: "Jalan Hapus"}).Error
+	assert.Nil(t, err)
+	err = db.Create(&UserLog{UserID: id, Action: "login"}).Error
+	assert.Nil(t, err)
+
+	todos := NewTodoService(db)
+	todo := Todo{Title: "Todo yang ikut terhapus"}
+	assert.Nil(t, todos.Create(ctx, id, &todo))
+	assert.Nil(t, todos.AddTags(ctx, id, todo.ID, "hapus"))
+
+	service := NewUserService(db)
+	err = db.Create(&Wallet{ID: id, UserID: id, Balance: 1000}).Error
+	assert.Nil(t, err)
+	err = service.Purge(ctx, id)
+	assert.ErrorIs(t, err, ErrDeleteRestricted)
+
+	err = db.Delete(&Wallet{}, "id = ?", id).Error
+	assert.Nil(t, err)
+	err = service.Purge(ctx, id)
+	assert.Nil(t, err)
+
+	var count int64
+	err = db.Table("addresses").Where("user_id = ?", id).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	err = db.Table("todos").Where("user_id = ?", id).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	err = db.Table("todo_tags").Where("todo_id = ?", todo.ID).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// log audit tetap ada tapi tidak menunjuk ke user lagi
+	err = db.Table("user_logs").Where("user_id = ?", id).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+}
+
+func TestScanOrphans(t *testing.T) {
+	ctx := context.Background()
+	missing := "orphan-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	action := "login " + missing
+
+	// row yatim cuma bisa dibuat dengan foreign key check dimatikan, setting
+	// nya per koneksi jadi semua statement harus di koneksi yang sama
+	err := db.Connection(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+			return err
+		}
+		defer tx.Exec("SET FOREIGN_KEY_CHECKS = 1")
+		if err := tx.Create(&Address{UserID: missing, Line1: "Jalan Yatim"}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserLog{UserID: missing, Action: action}).Error
+	})
+	assert.Nil(t, err)
+
+	orphans, err := ScanOrphans(ctx, db, "users", DefaultUserDeleteRules, false)
+	assert.Nil(t, err)
+	found := map[string]Orphans{}
+	for _, orphan := range orphans {
+		found[orphan.Table+"."+orphan.Column] = orphan
+	}
+	assert.GreaterOrEqual(t, found["addresses.user_id"].Count, int64(1))
+	assert.GreaterOrEqual(t, found["user_logs.user_id"].Count, int64(1))
+	assert.Equal(t, int64(0), found["addresses.user_id"].Removed)
+
+	orphans, err = ScanOrphans(ctx, db, "users", DefaultUserDeleteRules, true)
+	assert.Nil(t, err)
+	for _, orphan := range orphans {
+		assert.Equal(t, orphan.Count, orphan.Removed, orphan.Table+"."+orphan.Column)
+	}
+
+	// address dihapus, log tetap ada tapi user_id nya dikosongkan
+	var count int64
+	err = db.Table("addresses").Where("user_id = ?", missing).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	err = db.Model(&UserLog{}).Where("action = ? AND user_id IS NULL", action).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	orphans, err = ScanOrphans(ctx, db, "users", DefaultUserDeleteRules, false)
+	assert.Nil(t, err)
+	for _, orphan := range orphans {
+		assert.Equal(t, int64(0), orphan.Count, orphan.Table+"."+orphan.Column)
+	}
+
+	// foreign key baru bisa dipasang setelah row yatim dibersihkan
+	err = MigrateForeignKeys(db, "users", DefaultUserDeleteRules)
+	assert.Nil(t, err)
+}
+
+func TestUserSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	id := "soft-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := db.Create(&User{ID: id, Password: "rahasia", Name: Name{FirstName: "Nonaktif"}}).Error
+	assert.Nil(t, err)
+	err = db.Create(&Wallet{ID: id, UserID: id, Balance: 1000}).Error
+	assert.Nil(t, err)
+
+	todos := NewTodoService(db)
+	todo := Todo{Title: "Ikut nonaktif"}
+	assert.Nil(t, todos.Create(ctx, id, &todo))
+
+	service := NewUserService(db)
+	err = service.Delete(ctx, id)
+	assert.Nil(t, err)
+
+	_, err = service.Get(ctx, id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// wallet masih ada tapi usernya tidak ikut di Preload maupun Joins
+	var wallet Wallet
+	err = db.Preload("User").Take(&wallet, "id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Nil(t, wallet.User)
+
+	wallet = Wallet{}
+	err = db.Joins("User").Take(&wallet, "wallets.id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Nil(t, wallet.User)
+
+	_, err = todos.Get(ctx, id, todo.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	user, err := service.Reactivate(ctx, id)
+	assert.Nil(t, err)
+	assert.Equal(t, id, user.ID)
+
+	_, err = todos.Get(ctx, id, todo.ID)
+	assert.Nil(t, err)
+
+	// lewat masa retensi, tapi masih ada wallet jadi dilewati
+	err = service.Delete(ctx, id)
+	assert.Nil(t, err)
+	job := NewUserRetentionJob(db, service)
+	job.Now = func() time.Time { return time.Now().Add(job.Retention + time.Hour) }
+	_, err = job.RunOnce(ctx)
+	assert.Nil(t, err)
+
+	var count int64
+	err = db.Unscoped().Model(&User{}).Where("id = ?", id).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	err = db.Delete(&Wallet{}, "id = ?", id).Error
+	assert.Nil(t, err)
+	_, err = job.RunOnce(ctx)
+	assert.Nil(t, err)
+
+	err = db.Unscoped().Model(&User{}).Where("id = ?", id).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestNameFormat(t *testing.T) {
+	name := Name{FirstName: "Eko", MiddleName: "Kurniawan", LastName: "Khannedy"}
+	assert.Equal(t, "Eko Kurniawan Khannedy", name.Full())
+	assert.Equal(t, "EKK", name.Initials())
+	assert.Equal(t, "Khannedy Eko Kurniawan", name.FullFor("ja-JP"))
+	assert.Equal(t, "KEK", name.InitialsFor("hu"))
+	assert.Equal(t, "Eko Kurniawan Khannedy", name.FullFor("id-ID"))
+
+	name = Name{FirstName: " José ", LastName: "Ñúñez-Ørsted"}
+	assert.Equal(t, "José Ñúñez-Ørsted", name.Full())
+	assert.Equal(t, "JÑ", name.Initials())
+	assert.Equal(t, "jose nunez orsted", name.SearchKey())
+}
+
+func TestUserSearchName(t *testing.T) {
+	ctx := context.Background()
+	id := "search-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	user := User{ID: id, Password: "rahasia", Name: Name{FirstName: "Zoë", LastName: "Ångström"}}
+	err := db.Create(&user).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "zoe angstrom", user.SearchName)
+
+	service := NewUserService(db)
+	users, err := service.SearchByName(ctx, "ZOE ång", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+
+	// update sebagian nama, search_name dihitung ulang dari database
+	err = db.Model(&User{}).Where("id = ?", id).Update("last_name", "Müller").Error
+	assert.Nil(t, err)
+	users, err = service.SearchByName(ctx, "zoe mu", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+
+	err = db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{"middle_name": "Anne"}).Error
+	assert.Nil(t, err)
+	users, err = service.SearchByName(ctx, "zoe mu", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(users))
+	users, err = service.SearchByName(ctx, "zoe anne muller", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+
+	// kata terakhir harus lengkap kalau prefix diakhiri spasi
+	users, err = service.SearchByName(ctx, "zo ", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(users))
+
+	_, err = service.Update(ctx, id, UserUpdate{Name: &Name{FirstName: "Chloé"}})
+	assert.Nil(t, err)
+	var stored User
+	err = db.Take(&stored, "id = ?", id).Error
+	assert.Nil(t, err)
+	assert.Equal(t, "chloe", stored.SearchName)
+}
+
+func TestAccessControl(t *testing.T) {
+	ctx := context.Background()
+	id := "rbac-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := db.Create(&User{ID: id, Password: "rahasia", Name: Name{FirstName: "Moderator"}}).Error
+	assert.Nil(t, err)
+
+	access := NewAccessControl(db)
+	_, err = access.DefineRole(ctx, "test_moderator", PermissionGuestBookModerate)
+	assert.Nil(t, err)
+
+	allowed, err := access.Can(ctx, id, PermissionGuestBookModerate, "")
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+
+	err = access.Grant(ctx, "admin", id, "test_moderator", "guest_book:*")
+	assert.Nil(t, err)
+	// grant kedua kali tidak dicatat ulang
+	err = access.Grant(ctx, "admin", id, "test_moderator", "guest_book:*")
+	assert.Nil(t, err)
+
+	allowed, err = access.Can(ctx, id, PermissionGuestBookModerate, "guest_book:1")
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+	allowed, err = access.Can(ctx, id, PermissionGuestBookModerate, "")
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+	err = access.Authorize(ctx, id, PermissionProductsWrite, "product:P001")
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	err = access.Revoke(ctx, "admin", id, "test_moderator", "guest_book:*")
+	assert.Nil(t, err)
+	allowed, err = access.Can(ctx, id, PermissionGuestBookModerate, "guest_book:1")
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+
+	err = access.Grant(ctx, "admin", id, "tidak-ada", "")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	var logs []UserLog
+	err = db.Where("user_id = ? AND action LIKE ?", id, "%role%").Order("id asc").Find(&logs).Error
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, "grant role test_moderator on guest_book:* by admin", logs[0].Action)
+}
+
+func TestTenantScope(t *testing.T) {
+	scoped := OpenConnection()
+	err := scoped.Use(TenantScope{})
+	assert.Nil(t, err)
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	tenantA := WithTenant(context.Background(), "tenant-a-"+suffix)
+	tenantB := WithTenant(context.Background(), "tenant-b-"+suffix)
+
+	userA := User{ID: "tenant-a-" + suffix, Password: "rahasia", Name: Name{FirstName: "Tenant A"}}
+	err = scoped.WithContext(tenantA).Create(&userA).Error
+	assert.Nil(t, err)
+	assert.Equal(t, TenantID("tenant-a-"+suffix), userA.TenantID)
+	err = scoped.WithContext(tenantA).Create(&Wallet{ID: "tenant-a-" + suffix, UserID: userA.ID, Balance: 1000}).Error
+	assert.Nil(t, err)
+
+	userB := User{ID: "tenant-b-" + suffix, Password: "rahasia", Name: Name{FirstName: "Tenant B"}}
+	err = scoped.WithContext(tenantB).Create(&userB).Error
+	assert.Nil(t, err)
+
+	// tanpa tenant di context langsung gagal
+	var users []User
+	err = scoped.Find(&users, "id = ?", userA.ID).Error
+	assert.ErrorIs(t, err, ErrMissingTenant)
+	err = scoped.Create(&User{ID: "tenant-x-" + suffix}).Error
+	assert.ErrorIs(t, err, ErrMissingTenant)
+
+	err = scoped.WithContext(tenantB).Where("id = ?", userA.ID).Or("id = ?", userB.ID).Find(&users).Error
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, userB.ID, users[0].ID)
+
+	var user User
+	err = scoped.WithContext(tenantB).Take(&user, "id = ?", userA.ID).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	err = scoped.WithContext(tenantA).Preload("Wallet").Take(&user, "id = ?", userA.ID).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), user.Wallet.Balance)
+
+	// wallet tenant a yang user_id nya dipindah ke user tenant b tidak boleh
+	// ikut ke-join
+	err = scoped.WithContext(WithoutTenant(context.Background())).Create(&Wallet{
+		ID: "tenant-x-" + suffix, TenantID: TenantID("tenant-a-" + suffix), UserID: userB.ID,
+	}).Error
+	assert.Nil(t, err)
+	var wallet Wallet
+	err = scoped.WithContext(tenantA).Joins("User").Take(&wallet, "wallets.id = ?", "tenant-x-"+suffix).Error
+	assert.Nil(t, err)
+	assert.Nil(t, wallet.User)
+
+	// update dan delete tenant lain tidak kena row apa pun
+	result := scoped.WithContext(tenantB).Model(&Wallet{}).Where("id = ?", "tenant-a-"+suffix).Update("balance", 0)
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+	result = scoped.WithContext(tenantB).Delete(&Wallet{ID: "tenant-a-" + suffix})
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+	err = scoped.WithContext(tenantB).Model(&Wallet{}).Update("balance", 0).Error
+	assert.ErrorIs(t, err, gorm.ErrMissingWhereClause)
+
+	err = scoped.WithContext(tenantB).Create(&Wallet{ID: "tenant-y-" + suffix, TenantID: userA.TenantID, UserID: userA.ID}).Error
+	assert.ErrorIs(t, err, ErrTenantMismatch)
+
+	var count int64
+	err = scoped.WithContext(WithoutTenant(context.Background())).Model(&User{}).Where("id IN ?", []string{userA.ID, userB.ID}).Count(&count).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+}
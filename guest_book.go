@@ -1,17 +1,110 @@
 package belajar_golang_gorm
 
-import "time"
+import (
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+type ModerationStatus string
+
+const (
+	ModerationPending  ModerationStatus = "pending"
+	ModerationApproved ModerationStatus = "approved"
+	ModerationRejected ModerationStatus = "rejected"
+)
+
+const (
+	GuestBookNameMinLength    = 2
+	GuestBookNameMaxLength    = 100
+	GuestBookMessageMaxLength = 1000
+)
 
 // untuk migrator gorm
 type GuestBook struct {
-	ID        int64     `gorm:"primary_key;column:id;autoIncrement"`
-	Name      string    `gorm:"column:name"`
-	Email     string    `gorm:"column:email"`
-	Message   string    `gorm:"column:message"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	ID          int64            `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	TenantID    TenantID         `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	Name        string           `gorm:"column:name" json:"name"`
+	Email       string           `gorm:"column:email;size:512;serializer:encrypted" json:"email"`
+	EmailIndex  string           `gorm:"column:email_index;size:64;index" json:"-"`
+	Message     string           `gorm:"column:message" json:"message"`
+	Status      ModerationStatus `gorm:"column:status;type:varchar(20);default:pending;index" json:"status"`
+	SpamScore   float64          `gorm:"column:spam_score" json:"spam_score"`
+	ModeratedBy *string          `gorm:"column:moderated_by;size:100" json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time       `gorm:"column:moderated_at" json:"moderated_at,omitempty"`
+	IPAddress   string           `gorm:"column:ip_address;size:45" json:"-"`
+	CreatedAt   time.Time        `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+	UpdatedAt   time.Time        `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
 }
 
 func (g *GuestBook) TableName() string {
 	return "guest_books"
-}
\ No newline at end of file
+}
+
+// PublicGuestBook entry untuk pengunjung, email dan hasil moderasi cuma
+// boleh dilihat moderator
+type PublicGuestBook struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (g *GuestBook) Public() PublicGuestBook {
+	return PublicGuestBook{
+		ID:        g.ID,
+		Name:      g.Name,
+		Message:   g.Message,
+		CreatedAt: g.CreatedAt,
+	}
+}
+
+// email disimpan terenkripsi, pencarian email lewat email_index
+func (g *GuestBook) BeforeSave(tx *gorm.DB) error {
+	if g.Email == "" {
+		return nil
+	}
+	index, err := GuestBookEmailIndex(g.Email)
+	if err != nil {
+		return err
+	}
+	g.EmailIndex = index
+	return nil
+}
+
+// GuestBookEmailIndex nilai email_index untuk dicari dengan WHERE email_index = ?
+func GuestBookEmailIndex(email string) (string, error) {
+	keyring, err := CurrentKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.BlindIndex(NormalizeEmail(email)), nil
+}
+
+// Validate rapikan spasi lalu cek panjang nama, format email dan ukuran pesan
+func (g *GuestBook) Validate() error {
+	g.Name = strings.TrimSpace(g.Name)
+	g.Email = strings.TrimSpace(g.Email)
+	g.Message = strings.TrimSpace(g.Message)
+
+	fields := map[string]string{}
+	if n := utf8.RuneCountInString(g.Name); n < GuestBookNameMinLength || n > GuestBookNameMaxLength {
+		fields["name"] = "must be between 2 and 100 characters"
+	}
+	if address, err := mail.ParseAddress(g.Email); err != nil || address.Address != g.Email {
+		fields["email"] = "must be a valid email address"
+	}
+	if g.Message == "" {
+		fields["message"] = "must not be empty"
+	} else if utf8.RuneCountInString(g.Message) > GuestBookMessageMaxLength {
+		fields["message"] = "must be at most 1000 characters"
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"belajar_golang_gorm/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	GuestBookDefaultPageSize = 20
+	GuestBookMaxPageSize     = 100
+)
+
+var ErrInvalidCursor = pagination.ErrInvalidCursor
+
+// entry dengan skor di bawah AutoApproveBelow langsung tampil, di atas
+// AutoRejectAbove langsung ditolak, sisanya masuk antrian moderasi.
+// pengiriman dibatasi per email dan per ip lewat Limiter.
+type GuestBookService struct {
+	db *gorm.DB
+
+	Scorer           SpamScorer
+	AutoApproveBelow float64
+	AutoRejectAbove  float64
+
+	// secret cursor harus sama di semua instance, lihat pagination.NewSigner
+	Cursors *pagination.Signer
+
+	Limiter     *RateLimiter
+	EmailLimit  int
+	IPLimit     int
+	LimitWindow time.Duration
+}
+
+func NewGuestBookService(db *gorm.DB) *GuestBookService {
+	return &GuestBookService{
+		db:               db,
+		Scorer:           NewRuleSpamScorer(db),
+		AutoApproveBelow: 0.2,
+		AutoRejectAbove:  0.8,
+		Limiter:          NewRateLimiter(db),
+		EmailLimit:       5,
+		IPLimit:          20,
+		LimitWindow:      time.Hour,
+		Cursors:          pagination.NewRandomSigner(),
+	}
+}
+
+type GuestBookPage = pagination.Page[GuestBook]
+
+func (s *GuestBookService) Create(ctx context.Context, entry *GuestBook) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	index, err := GuestBookEmailIndex(entry.Email)
+	if err != nil {
+		return err
+	}
+	entry.EmailIndex = index
+
+	// key pakai blind index supaya email tidak tersimpan apa adanya
+	rules := []RateLimitRule{{
+		Key:    guestBookLimitKey(ctx, "email", index),
+		Limit:  s.EmailLimit,
+		Window: s.LimitWindow,
+	}}
+	if entry.IPAddress != "" {
+		rules = append(rules, RateLimitRule{
+			Key:    guestBookLimitKey(ctx, "ip", entry.IPAddress),
+			Limit:  s.IPLimit,
+			Window: s.LimitWindow,
+		})
+	}
+	if err := s.Limiter.Allow(ctx, rules...); err != nil {
+		return err
+	}
+
+	score, err := s.Scorer.Score(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.SpamScore = score
+	switch {
+	case score < s.AutoApproveBelow:
+		entry.Status = ModerationApproved
+	case score > s.AutoRejectAbove:
+		entry.Status = ModerationRejected
+	default:
+		entry.Status = ModerationPending
+	}
+
+	return s.db.WithContext(ctx).Create(entry).Error
+}
+
+// guestBookLimitKey key rate limit per tenant, email atau ip yang sama di
+// tenant lain punya jatah sendiri
+func guestBookLimitKey(ctx context.Context, kind string, value string) string {
+	tenantID, _ := TenantFromContext(ctx)
+	return "guest_book:" + tenantID + ":" + kind + ":" + value
+}
+
+// List ambil entry yang sudah approved, terbaru dulu
+func (s *GuestBookService) List(ctx context.Context, cursor string, limit int) (*GuestBookPage, error) {
+	return s.listByStatus(ctx, ModerationApproved, cursor, limit)
+}
+
+// PendingQueue antrian entry yang menunggu review moderator
+func (s *GuestBookService) PendingQueue(ctx context.Context, cursor string, limit int) (*GuestBookPage, error) {
+	return s.listByStatus(ctx, ModerationPending, cursor, limit)
+}
+
+func (s *GuestBookService) Approve(ctx context.Context, moderatorID string, id int64) (*GuestBook, error) {
+	return s.moderate(ctx, moderatorID, id, ModerationApproved)
+}
+
+func (s *GuestBookService) Reject(ctx context.Context, moderatorID string, id int64) (*GuestBook, error) {
+	return s.moderate(ctx, moderatorID, id, ModerationRejected)
+}
+
+// moderate cuma bisa untuk entry yang masih pending, keputusan dicatat ke user_logs
+func (s *GuestBookService) moderate(ctx context.Context, moderatorID string, id int64, status ModerationStatus) (*GuestBook, error) {
+	var entry GuestBook
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&entry, "id = ? AND status = ?", id, ModerationPending).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &NotFoundError{Resource: "pending guest book entry", ID: strconv.FormatInt(id, 10)}
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		entry.Status = status
+		entry.ModeratedBy = &moderatorID
+		entry.ModeratedAt = &now
+		err = tx.Model(&entry).Select("status", "moderated_by", "moderated_at").Updates(&entry).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&UserLog{
+			UserID: moderatorID,
+			Action: fmt.Sprintf("%s guest book %d", status, id),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+var guestBookPageKeys = []pagination.Key{
+	{Column: "created_at", Desc: true},
+	{Column: "id", Desc: true},
+}
+
+// listByStatus pakai keyset pagination (created_at, id) terbaru dulu,
+// cursor kosong berarti halaman pertama
+func (s *GuestBookService) listByStatus(ctx context.Context, status ModerationStatus, cursor string, limit int) (*GuestBookPage, error) {
+	if limit <= 0 {
+		limit = GuestBookDefaultPageSize
+	}
+	limit = min(limit, GuestBookMaxPageSize)
+
+	query := s.db.WithContext(ctx).Where("status = ?", status)
+	return pagination.Find[GuestBook](query, s.Cursors, guestBookPageKeys, cursor, limit)
+}
@@ -0,0 +1,74 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SpamScorer kasih nilai 0 (bersih) sampai 1 (pasti spam) untuk entry
+// guest book yang belum disimpan
+type SpamScorer interface {
+	Score(ctx context.Context, entry *GuestBook) (float64, error)
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+
+// RuleSpamScorer scorer bawaan berdasarkan jumlah link, kata terlarang dan
+// seberapa sering email yang sama mengirim pesan
+type RuleSpamScorer struct {
+	db *gorm.DB
+
+	LinkWeight    float64
+	BlockedWords  []string
+	BlockedWeight float64
+	RepeatWindow  time.Duration
+	RepeatLimit   int
+	RepeatWeight  float64
+}
+
+func NewRuleSpamScorer(db *gorm.DB) *RuleSpamScorer {
+	return &RuleSpamScorer{
+		db:            db,
+		LinkWeight:    0.25,
+		BlockedWords:  []string{"casino", "viagra", "crypto giveaway", "judi online", "slot gacor"},
+		BlockedWeight: 0.5,
+		RepeatWindow:  time.Hour,
+		RepeatLimit:   3,
+		RepeatWeight:  0.5,
+	}
+}
+
+func (s *RuleSpamScorer) Score(ctx context.Context, entry *GuestBook) (float64, error) {
+	score := 0.0
+
+	links := len(linkPattern.FindAllStringIndex(entry.Message, -1))
+	score += float64(links) * s.LinkWeight
+
+	text := strings.ToLower(entry.Name + " " + entry.Message)
+	for _, word := range s.BlockedWords {
+		if strings.Contains(text, strings.ToLower(word)) {
+			score += s.BlockedWeight
+		}
+	}
+
+	index, err := GuestBookEmailIndex(entry.Email)
+	if err != nil {
+		return 0, err
+	}
+	var recent int64
+	err = s.db.WithContext(ctx).Model(&GuestBook{}).
+		Where("email_index = ? AND created_at >= ?", index, time.Now().Add(-s.RepeatWindow)).
+		Count(&recent).Error
+	if err != nil {
+		return 0, err
+	}
+	if recent >= int64(s.RepeatLimit) {
+		score += s.RepeatWeight
+	}
+
+	return min(score, 1), nil
+}
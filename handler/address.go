@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+type AddressHandler struct {
+	service *gormapp.AddressService
+	auth    *Authorizer
+}
+
+func NewAddressHandler(service *gormapp.AddressService, auth *Authorizer) *AddressHandler {
+	return &AddressHandler{service: service, auth: auth}
+}
+
+func (h *AddressHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{userID}/addresses", h.List)
+	mux.HandleFunc("POST /users/{userID}/addresses", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.Create))
+	mux.HandleFunc("GET /users/{userID}/addresses/{id}", h.Get)
+	mux.HandleFunc("PATCH /users/{userID}/addresses/{id}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.Update))
+	mux.HandleFunc("DELETE /users/{userID}/addresses/{id}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.Delete))
+	mux.HandleFunc("GET /users/{userID}/addresses/default/{kind}", h.Default)
+	mux.HandleFunc("PUT /users/{userID}/addresses/default/{kind}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.SetDefault))
+}
+
+type createAddressRequest struct {
+	Label           string `json:"label"`
+	Line1           string `json:"line1"`
+	Line2           string `json:"line2"`
+	City            string `json:"city"`
+	Region          string `json:"region"`
+	PostalCode      string `json:"postal_code"`
+	CountryCode     string `json:"country_code"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
+type updateAddressRequest struct {
+	Label       *string          `json:"label"`
+	Line1       *string          `json:"line1"`
+	Line2       *string          `json:"line2"`
+	City        *string          `json:"city"`
+	Region      *string          `json:"region"`
+	PostalCode  *string          `json:"postal_code"`
+	CountryCode *string          `json:"country_code"`
+	Version     *gormapp.Version `json:"version"`
+}
+
+type setDefaultAddressRequest struct {
+	AddressID int64 `json:"address_id"`
+}
+
+func (h *AddressHandler) List(w http.ResponseWriter, r *http.Request) {
+	query, ok := listQuery(w, r, gormapp.AddressFilterSchema)
+	if !ok {
+		return
+	}
+	addresses, err := h.service.List(r.Context(), r.PathValue("userID"), query)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request createAddressRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	// validasi field ada di AddressService
+	address := gormapp.Address{
+		Label:           request.Label,
+		Line1:           request.Line1,
+		Line2:           request.Line2,
+		City:            request.City,
+		Region:          request.Region,
+		PostalCode:      request.PostalCode,
+		CountryCode:     request.CountryCode,
+		DefaultShipping: gormapp.DefaultFlag(request.DefaultShipping),
+		DefaultBilling:  gormapp.DefaultFlag(request.DefaultBilling),
+	}
+	if err := h.service.Create(r.Context(), r.PathValue("userID"), &address); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, address)
+}
+
+func (h *AddressHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt64(w, r, "id")
+	if !ok {
+		return
+	}
+	address, err := h.service.Get(r.Context(), r.PathValue("userID"), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt64(w, r, "id")
+	if !ok {
+		return
+	}
+	var request updateAddressRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	address, err := h.service.Update(r.Context(), r.PathValue("userID"), id, gormapp.AddressUpdate{
+		Label:       request.Label,
+		Line1:       request.Line1,
+		Line2:       request.Line2,
+		City:        request.City,
+		Region:      request.Region,
+		PostalCode:  request.PostalCode,
+		CountryCode: request.CountryCode,
+		Version:     request.Version,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt64(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(r.Context(), r.PathValue("userID"), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AddressHandler) Default(w http.ResponseWriter, r *http.Request) {
+	kind := gormapp.AddressDefault(r.PathValue("kind"))
+	address, err := h.service.Default(r.Context(), r.PathValue("userID"), kind)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+// SetDefault ganti alamat default, default yang lama otomatis dilepas
+func (h *AddressHandler) SetDefault(w http.ResponseWriter, r *http.Request) {
+	var request setDefaultAddressRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	kind := gormapp.AddressDefault(r.PathValue("kind"))
+	address, err := h.service.SetDefault(r.Context(), r.PathValue("userID"), request.AddressID, kind)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+// Authorizer middleware RBAC, user diambil dari header X-User-ID
+type Authorizer struct {
+	access *gormapp.AccessControl
+}
+
+func NewAuthorizer(access *gormapp.AccessControl) *Authorizer {
+	return &Authorizer{access: access}
+}
+
+// ResourceFunc nama resource yang dicek untuk satu request
+type ResourceFunc func(r *http.Request) string
+
+// PathResource resource "kind:<path value>", misalnya PathResource("user", "id")
+// untuk /users/{id} jadi "user:1"
+func PathResource(kind string, name string) ResourceFunc {
+	return func(r *http.Request) string {
+		return kind + ":" + r.PathValue(name)
+	}
+}
+
+// Require handler cuma dijalankan kalau user punya permission di resource,
+// resource nil berarti permission global. tanpa header 401, tidak boleh 403.
+func (a *Authorizer) Require(permission string, resource ResourceFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := requireUserID(w, r)
+		if !ok {
+			return
+		}
+		name := ""
+		if resource != nil {
+			name = resource(r)
+		}
+		if err := a.access.Authorize(r.Context(), userID, permission, name); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireSelfOr seperti Require dengan resource "user:<id>", tapi user yang
+// id nya sama dengan path value userParam selalu boleh tanpa permission
+func (a *Authorizer) RequireSelfOr(permission string, userParam string, next http.HandlerFunc) http.HandlerFunc {
+	other := a.Require(permission, PathResource("user", userParam), next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userID := r.Header.Get(userIDHeader); userID != "" && userID == r.PathValue(userParam) {
+			next(w, r)
+			return
+		}
+		other(w, r)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/pagination"
+)
+
+type GuestBookHandler struct {
+	service *gormapp.GuestBookService
+	auth    *Authorizer
+}
+
+func NewGuestBookHandler(service *gormapp.GuestBookService, auth *Authorizer) *GuestBookHandler {
+	return &GuestBookHandler{service: service, auth: auth}
+}
+
+func (h *GuestBookHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /guest-books", h.Create)
+	mux.HandleFunc("GET /guest-books", h.List)
+	mux.HandleFunc("GET /guest-books/pending", h.auth.Require(gormapp.PermissionGuestBookModerate, nil, h.Pending))
+	mux.HandleFunc("POST /guest-books/{id}/approve", h.auth.Require(gormapp.PermissionGuestBookModerate, PathResource("guest_book", "id"), h.Approve))
+	mux.HandleFunc("POST /guest-books/{id}/reject", h.auth.Require(gormapp.PermissionGuestBookModerate, PathResource("guest_book", "id"), h.Reject))
+}
+
+type createGuestBookRequest struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+}
+
+func (h *GuestBookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request createGuestBookRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	entry := gormapp.GuestBook{
+		Name:      request.Name,
+		Email:     request.Email,
+		Message:   request.Message,
+		IPAddress: clientIP(r),
+	}
+	if err := h.service.Create(r.Context(), &entry); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	// pengirim tidak perlu tahu spam score entry nya
+	writeJSON(w, http.StatusCreated, entry.Public())
+}
+
+// List terima query ?cursor=...&limit=..., cuma entry yang sudah approved.
+// endpoint publik, jadi email dan data moderasi tidak ikut
+func (h *GuestBookHandler) List(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.readPage(w, r, h.service.List)
+	if !ok {
+		return
+	}
+
+	items := make([]gormapp.PublicGuestBook, 0, len(page.Items))
+	for i := range page.Items {
+		items = append(items, page.Items[i].Public())
+	}
+	writeJSON(w, http.StatusOK, pagination.Page[gormapp.PublicGuestBook]{
+		Items:      items,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	})
+}
+
+// Pending khusus moderator, entry dikirim lengkap dengan email dan spam score
+func (h *GuestBookHandler) Pending(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.readPage(w, r, h.service.PendingQueue)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (h *GuestBookHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, h.service.Approve)
+}
+
+func (h *GuestBookHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, h.service.Reject)
+}
+
+type pageFunc func(ctx context.Context, cursor string, limit int) (*gormapp.GuestBookPage, error)
+
+func (h *GuestBookHandler) readPage(w http.ResponseWriter, r *http.Request, list pageFunc) (*gormapp.GuestBookPage, bool) {
+	limit, ok := queryInt(w, r, "limit")
+	if !ok {
+		return nil, false
+	}
+
+	page, err := list(r.Context(), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return nil, false
+	}
+	return page, true
+}
+
+type moderateFunc func(ctx context.Context, moderatorID string, id int64) (*gormapp.GuestBook, error)
+
+func (h *GuestBookHandler) moderate(w http.ResponseWriter, r *http.Request, action moderateFunc) {
+	moderatorID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathInt64(w, r, "id")
+	if !ok {
+		return
+	}
+
+	entry, err := action(r.Context(), moderatorID, id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
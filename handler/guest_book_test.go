@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/pagination"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+)
+
+// newTestMux request tanpa X-Tenant-ID dijalankan di tenant "default"
+func newTestMux(t *testing.T) http.Handler {
+	db, err := gormapp.OpenDatabase(gormapp.DefaultDSN, logger.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := gormapp.NewKeyring("test", map[string][]byte{"test": bytes.Repeat([]byte("k"), 32)}, []byte("blind-index-test-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormapp.SetKeyring(keyring)
+
+	router := NewRouter(db, pagination.NewRandomSigner())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(tenantIDHeader) == "" {
+			r.Header.Set(tenantIDHeader, "default")
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+func TestGuestBookCreate(t *testing.T) {
+	mux := newTestMux(t)
+
+	body := `{"name":"Eko","email":"eko@example.com","message":"Halo semua"}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/guest-books", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var entry gormapp.GuestBook
+	err := json.Unmarshal(recorder.Body.Bytes(), &entry)
+	assert.Nil(t, err)
+	assert.NotEqual(t, int64(0), entry.ID)
+	assert.NotContains(t, recorder.Body.String(), "eko@example.com")
+	assert.NotContains(t, recorder.Body.String(), "spam_score")
+}
+
+func TestGuestBookCreateInvalid(t *testing.T) {
+	mux := newTestMux(t)
+
+	body := `{"name":"E","email":"bukan email","message":""}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/guest-books", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+	var response errorBody
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "validation_failed", response.Error.Code)
+	assert.Len(t, response.Error.Fields, 3)
+}
+
+func TestGuestBookList(t *testing.T) {
+	mux := newTestMux(t)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/guest-books?limit=1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var page pagination.Page[map[string]interface{}]
+	err := json.Unmarshal(recorder.Body.Bytes(), &page)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, len(page.Items), 1)
+	for _, item := range page.Items {
+		assert.NotContains(t, item, "email")
+		assert.NotContains(t, item, "spam_score")
+		assert.NotContains(t, item, "status")
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/guest-books?cursor=rusak", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,96 @@
+// Package handler berisi http handler di atas service belajar_golang_gorm
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/filter"
+
+	"gorm.io/gorm"
+)
+
+// batas ukuran body request json
+const maxBodyBytes = 64 << 10
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeServiceError ubah error dari service jadi response json yang konsisten
+func writeServiceError(w http.ResponseWriter, err error) {
+	var validation *gormapp.ValidationError
+	var limited *gormapp.RateLimitError
+	switch {
+	case errors.As(err, &validation):
+		writeJSON(w, http.StatusUnprocessableEntity, errorBody{Error: errorDetail{
+			Code:    "validation_failed",
+			Message: "request has invalid fields",
+			Fields:  validation.Fields,
+		}})
+	case errors.As(err, &limited):
+		seconds := int(math.Ceil(limited.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeError(w, http.StatusTooManyRequests, "rate_limited", limited.Error())
+	case errors.Is(err, filter.ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, "invalid_query", err.Error())
+	case errors.Is(err, gormapp.ErrInvalidCursor):
+		writeError(w, http.StatusBadRequest, "invalid_cursor", err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, gormapp.ErrInsufficientBalance):
+		writeError(w, http.StatusConflict, "insufficient_balance", err.Error())
+	case errors.Is(err, gormapp.ErrPermissionDenied):
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, gormapp.ErrDeleteRestricted):
+		writeError(w, http.StatusConflict, "delete_restricted", err.Error())
+	case errors.Is(err, gormapp.ErrStaleObject):
+		writeError(w, http.StatusConflict, "stale_object", "resource was modified by another request, reload and retry")
+	// nama constraint dan nilainya tidak dikirim ke client
+	case errors.Is(err, gormapp.ErrDuplicate):
+		writeError(w, http.StatusConflict, "duplicate", "resource already exists")
+	case errors.Is(err, gormapp.ErrForeignKey):
+		writeError(w, http.StatusConflict, "foreign_key_violation", "referenced resource is missing or still in use")
+	case errors.Is(err, gormapp.ErrDeadlock), errors.Is(err, gormapp.ErrLockTimeout):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, "busy", "resource is busy, try again")
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
+	}
+}
+
+// decodeJSON baca body json ke dst, field yang tidak dikenal ditolak
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "body_too_large", "request body is too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return false
+	}
+	return true
+}
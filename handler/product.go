@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+type ProductHandler struct {
+	service *gormapp.ProductService
+	auth    *Authorizer
+}
+
+func NewProductHandler(service *gormapp.ProductService, auth *Authorizer) *ProductHandler {
+	return &ProductHandler{service: service, auth: auth}
+}
+
+func (h *ProductHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /products", h.auth.Require(gormapp.PermissionProductsWrite, nil, h.Create))
+	mux.HandleFunc("GET /products", h.List)
+	mux.HandleFunc("GET /products/{id}", h.Get)
+	mux.HandleFunc("PATCH /products/{id}", h.auth.Require(gormapp.PermissionProductsWrite, PathResource("product", "id"), h.Update))
+	mux.HandleFunc("DELETE /products/{id}", h.auth.Require(gormapp.PermissionProductsWrite, PathResource("product", "id"), h.Delete))
+
+	mux.HandleFunc("GET /users/{userID}/liked-products", h.Liked)
+	mux.HandleFunc("PUT /users/{userID}/liked-products/{id}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.Like))
+	mux.HandleFunc("DELETE /users/{userID}/liked-products/{id}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "userID", h.Unlike))
+}
+
+type createProductRequest struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int64  `json:"price"`
+}
+
+type updateProductRequest struct {
+	Name    *string          `json:"name"`
+	Price   *int64           `json:"price"`
+	Version *gormapp.Version `json:"version"`
+}
+
+func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request createProductRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	fields := map[string]string{}
+	if request.ID == "" {
+		fields["id"] = "must not be empty"
+	}
+	if request.Name == "" {
+		fields["name"] = "must not be empty"
+	}
+	if request.Price < 0 {
+		fields["price"] = "must not be negative"
+	}
+	if len(fields) > 0 {
+		writeServiceError(w, &gormapp.ValidationError{Fields: fields})
+		return
+	}
+
+	product := gormapp.Product{ID: request.ID, Name: request.Name, Price: request.Price}
+	if err := h.service.Create(r.Context(), &product); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, product)
+}
+
+func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := limitOffset(w, r)
+	if !ok {
+		return
+	}
+	query, ok := listQuery(w, r, gormapp.ProductFilterSchema)
+	if !ok {
+		return
+	}
+	products, err := h.service.List(r.Context(), query, limit, offset)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (h *ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
+	product, err := h.service.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var request updateProductRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Price != nil && *request.Price < 0 {
+		writeServiceError(w, &gormapp.ValidationError{Fields: map[string]string{"price": "must not be negative"}})
+		return
+	}
+
+	product, err := h.service.Update(r.Context(), r.PathValue("id"), gormapp.ProductUpdate{
+		Name:    request.Name,
+		Price:   request.Price,
+		Version: request.Version,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ProductHandler) Liked(w http.ResponseWriter, r *http.Request) {
+	query, ok := listQuery(w, r, gormapp.ProductFilterSchema)
+	if !ok {
+		return
+	}
+	products, err := h.service.LikedProducts(r.Context(), r.PathValue("userID"), query)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (h *ProductHandler) Like(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Like(r.Context(), r.PathValue("userID"), r.PathValue("id")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ProductHandler) Unlike(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Unlike(r.Context(), r.PathValue("userID"), r.PathValue("id")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
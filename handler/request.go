@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/filter"
+
+	"gorm.io/gorm"
+)
+
+// header berisi id user yang sedang login dan tenant nya, diisi oleh gateway
+// auth di depan
+const (
+	userIDHeader   = "X-User-ID"
+	tenantIDHeader = "X-Tenant-ID"
+)
+
+func requireUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get(userIDHeader)
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "missing "+userIDHeader+" header")
+		return "", false
+	}
+	return userID, true
+}
+
+// requireTenant semua query di handler berikutnya cuma melihat data tenant
+// dari header X-Tenant-ID, request tanpa header ditolak
+func requireTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.Header.Get(tenantIDHeader)
+		if tenantID == "" {
+			writeError(w, http.StatusBadRequest, "missing_tenant", "missing "+tenantIDHeader+" header")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(gormapp.WithTenant(r.Context(), tenantID)))
+	})
+}
+
+// requireTenantMember user dari header X-User-ID harus terdaftar di tenant
+// request, dipasang setelah requireTenant. role tidak per tenant, tanpa cek
+// ini admin di satu tenant bisa memakai role nya di tenant lain cukup dengan
+// mengganti X-Tenant-ID. request tanpa X-User-ID diteruskan, endpoint yang
+// butuh login menolaknya lewat requireUserID.
+func requireTenantMember(db *gorm.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(userIDHeader)
+		if userID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var user gormapp.User
+		err := db.WithContext(r.Context()).Select("id").Take(&user, "id = ?", userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeServiceError(w, fmt.Errorf("%w: user %s is not a member of this tenant", gormapp.ErrPermissionDenied, userID))
+			return
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP ambil host dari RemoteAddr, header X-Forwarded-For tidak dipercaya
+// karena bisa diisi bebas oleh client
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func pathInt64(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	value, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_"+name, name+" must be a number")
+		return 0, false
+	}
+	return value, true
+}
+
+// queryInt baca query parameter angka positif, kosong berarti 0
+func queryInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	text := r.URL.Query().Get(name)
+	if text == "" {
+		return 0, true
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_"+name, name+" must be a positive number")
+		return 0, false
+	}
+	return value, true
+}
+
+// listQuery baca ?filter, ?sort dan ?fields sesuai whitelist schema
+func listQuery(w http.ResponseWriter, r *http.Request, schema filter.Schema) (*filter.Query, bool) {
+	query, err := filter.Parse(r.URL.Query(), schema)
+	if err != nil {
+		writeServiceError(w, err)
+		return nil, false
+	}
+	return query, true
+}
+
+func limitOffset(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	limit, ok := queryInt(w, r, "limit")
+	if !ok {
+		return 0, 0, false
+	}
+	offset, ok := queryInt(w, r, "offset")
+	if !ok {
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
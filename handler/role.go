@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+type RoleHandler struct {
+	access *gormapp.AccessControl
+	auth   *Authorizer
+}
+
+func NewRoleHandler(access *gormapp.AccessControl, auth *Authorizer) *RoleHandler {
+	return &RoleHandler{access: access, auth: auth}
+}
+
+func (h *RoleHandler) Register(mux *http.ServeMux) {
+	user := PathResource("user", "userID")
+	mux.HandleFunc("GET /users/{userID}/roles", h.auth.Require(gormapp.PermissionRolesManage, user, h.List))
+	mux.HandleFunc("PUT /users/{userID}/roles/{role}", h.auth.Require(gormapp.PermissionRolesManage, user, h.Grant))
+	mux.HandleFunc("DELETE /users/{userID}/roles/{role}", h.auth.Require(gormapp.PermissionRolesManage, user, h.Revoke))
+}
+
+func (h *RoleHandler) List(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.access.Grants(r.Context(), r.PathValue("userID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// Grant role untuk semua resource, atau satu resource lewat ?resource=product:P001.
+// actor harus punya semua permission role itu di resource yang sama, lihat
+// AccessControl.AuthorizeGrant
+func (h *RoleHandler) Grant(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.access.Grant)
+}
+
+// Revoke ?resource harus sama dengan waktu Grant
+func (h *RoleHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.access.Revoke)
+}
+
+type roleChangeFunc func(ctx context.Context, actorID string, userID string, role string, resource string) error
+
+func (h *RoleHandler) change(w http.ResponseWriter, r *http.Request, change roleChangeFunc) {
+	// header sudah dicek oleh Require
+	actorID := r.Header.Get(userIDHeader)
+	role := r.PathValue("role")
+	resource := r.URL.Query().Get("resource")
+	if err := h.access.AuthorizeGrant(r.Context(), actorID, role, resource); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	err := change(r.Context(), actorID, r.PathValue("userID"), role, resource)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+	"belajar_golang_gorm/pagination"
+
+	"gorm.io/gorm"
+)
+
+// NewRouter daftarkan semua endpoint ke satu mux. cursors dipakai untuk
+// tanda tangan cursor pagination, harus sama di semua instance. setiap
+// request wajib membawa header X-Tenant-ID, dan X-User-ID kalau ada harus
+// user di tenant itu.
+func NewRouter(db *gorm.DB, cursors *pagination.Signer) http.Handler {
+	guestBooks := gormapp.NewGuestBookService(db)
+	guestBooks.Cursors = cursors
+
+	access := gormapp.NewAccessControl(db)
+	auth := NewAuthorizer(access)
+
+	mux := http.NewServeMux()
+	NewUserHandler(gormapp.NewUserService(db), auth).Register(mux)
+	NewRoleHandler(access, auth).Register(mux)
+	NewAddressHandler(gormapp.NewAddressService(db), auth).Register(mux)
+	NewWalletHandler(gormapp.NewWalletService(db), auth).Register(mux)
+	NewProductHandler(gormapp.NewProductService(db), auth).Register(mux)
+	NewGuestBookHandler(guestBooks, auth).Register(mux)
+	return requireTenant(requireTenantMember(db, mux))
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+type UserHandler struct {
+	service *gormapp.UserService
+	auth    *Authorizer
+}
+
+func NewUserHandler(service *gormapp.UserService, auth *Authorizer) *UserHandler {
+	return &UserHandler{service: service, auth: auth}
+}
+
+func (h *UserHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /users", h.Create)
+	mux.HandleFunc("GET /users", h.List)
+	mux.HandleFunc("GET /users/search", h.Search)
+	mux.HandleFunc("GET /users/{id}", h.Get)
+	mux.HandleFunc("PATCH /users/{id}", h.auth.RequireSelfOr(gormapp.PermissionUsersWrite, "id", h.Update))
+	mux.HandleFunc("DELETE /users/{id}", h.auth.Require(gormapp.PermissionUsersDelete, PathResource("user", "id"), h.Delete))
+	mux.HandleFunc("POST /users/{id}/reactivate", h.auth.Require(gormapp.PermissionUsersReactivate, PathResource("user", "id"), h.Reactivate))
+}
+
+type createUserRequest struct {
+	ID       string       `json:"id"`
+	Password string       `json:"password"`
+	Name     gormapp.Name `json:"name"`
+}
+
+type updateUserRequest struct {
+	Password *string          `json:"password"`
+	Name     *gormapp.Name    `json:"name"`
+	Version  *gormapp.Version `json:"version"`
+}
+
+func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request createUserRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	fields := map[string]string{}
+	if request.Password == "" {
+		fields["password"] = "must not be empty"
+	}
+	if request.Name.FirstName == "" {
+		fields["name.first_name"] = "must not be empty"
+	}
+	if len(fields) > 0 {
+		writeServiceError(w, &gormapp.ValidationError{Fields: fields})
+		return
+	}
+
+	user := gormapp.User{
+		ID:       request.ID,
+		Password: request.Password,
+		Name:     request.Name,
+	}
+	if err := h.service.Create(r.Context(), &user); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := limitOffset(w, r)
+	if !ok {
+		return
+	}
+	query, ok := listQuery(w, r, gormapp.UserFilterSchema)
+	if !ok {
+		return
+	}
+	users, err := h.service.List(r.Context(), query, limit, offset)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// Search cari user dari awal nama lengkapnya, ?q=eko%20k
+func (h *UserHandler) Search(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := limitOffset(w, r)
+	if !ok {
+		return
+	}
+	users, err := h.service.SearchByName(r.Context(), r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, err := h.service.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var request updateUserRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	user, err := h.service.Update(r.Context(), r.PathValue("id"), gormapp.UserUpdate{
+		Password: request.Password,
+		Name:     request.Name,
+		Version:  request.Version,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Delete cuma menonaktifkan user, data dihapus permanen setelah masa retensi
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	user, err := h.service.Reactivate(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
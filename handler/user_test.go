@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gormapp "belajar_golang_gorm"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+)
+
+func TestUserCreateHidesPassword(t *testing.T) {
+	mux := newTestMux(t)
+
+	id := "http-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "rahasia")
+	assert.NotContains(t, recorder.Body.String(), "password")
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var user map[string]interface{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &user)
+	assert.Nil(t, err)
+	assert.Equal(t, id, user["id"])
+	assert.NotContains(t, user, "password")
+}
+
+func TestUserNotFound(t *testing.T) {
+	mux := newTestMux(t)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/tidak-ada", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+// asUser request dengan header X-User-ID, userID kosong berarti tanpa login
+func asUser(method string, target string, body string, userID string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if userID != "" {
+		request.Header.Set("X-User-ID", userID)
+	}
+	return request
+}
+
+func TestLikeUnlikeProduct(t *testing.T) {
+	mux := newTestMux(t)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPut, "/users/1/liked-products/P002", "", "1"))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	// like kedua kali tetap berhasil
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPut, "/users/1/liked-products/P002", "", "1"))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodDelete, "/users/1/liked-products/P002", "", "1"))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	// like atas nama user lain butuh users.write
+	stranger := "stranger-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	createTestUser(t, mux, stranger, "default")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPut, "/users/1/liked-products/P002", "", stranger))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestUserUpdateRequiresSelfOrPermission(t *testing.T) {
+	mux := newTestMux(t)
+
+	id := "http-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	// user di tenant yang sama tapi tanpa role apa pun, 403 nya dari RBAC
+	// bukan dari cek anggota tenant
+	stranger := "stranger-" + id
+	createTestUser(t, mux, stranger, "default")
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	update := `{"name":{"first_name":"Budi"}}`
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPatch, "/users/"+id, update, ""))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPatch, "/users/"+id, update, stranger))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPatch, "/users/"+id, update, id))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// alamat user lain juga tidak boleh diubah
+	address := `{"line1":"Jalan Palsu","city":"Jakarta","country_code":"ID"}`
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPost, "/users/"+id+"/addresses", address, ""))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPost, "/users/"+id+"/addresses", address, stranger))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPut, "/users/"+id+"/addresses/default/shipping", `{"address_id":1}`, stranger))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestWalletWriteRequiresPermission(t *testing.T) {
+	mux := newTestMux(t)
+
+	id := "http-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	// user di tenant yang sama tapi tanpa role apa pun, 403 nya dari RBAC
+	// bukan dari cek anggota tenant
+	stranger := "stranger-" + id
+	createTestUser(t, mux, stranger, "default")
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPost, "/users/"+id+"/wallet", `{"id":"`+id+`","balance":1000000}`, ""))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// pemilik wallet sendiri juga tidak boleh menambah saldo
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPost, "/users/"+id+"/wallet", `{"id":"`+id+`","balance":1000000}`, id))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPatch, "/users/"+id+"/wallet", `{"amount":1000000}`, id))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodDelete, "/users/"+id+"/wallet", "", stranger))
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestUserDeleteRequiresPermission(t *testing.T) {
+	mux := newTestMux(t)
+
+	id := "http-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/users/"+id, nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// user biasa tidak punya role apa pun, termasuk untuk dirinya sendiri
+	request := httptest.NewRequest(http.MethodDelete, "/users/"+id, nil)
+	request.Header.Set("X-User-ID", id)
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+// createTestUser buat user lewat POST /users di tenant yang diberikan
+func createTestUser(t *testing.T, mux http.Handler, id string, tenant string) {
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	request := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	request.Header.Set("X-Tenant-ID", tenant)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+}
+
+func TestGrantRequiresPermissionsOfRole(t *testing.T) {
+	mux := newTestMux(t)
+	db, err := gormapp.OpenDatabase(gormapp.DefaultDSN, logger.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	manager := "manager-" + suffix
+	target := "target-" + suffix
+	createTestUser(t, mux, manager, "default")
+	createTestUser(t, mux, target, "default")
+
+	// roles.manage cuma untuk resource user, products.write cuma untuk P001
+	ctx := gormapp.WithTenant(context.Background(), "default")
+	access := gormapp.NewAccessControl(db)
+	_, err = access.DefineRole(ctx, "admin", gormapp.PermissionAll)
+	assert.Nil(t, err)
+	_, err = access.DefineRole(ctx, "test_role_manager", gormapp.PermissionRolesManage)
+	assert.Nil(t, err)
+	_, err = access.DefineRole(ctx, "test_catalog", gormapp.PermissionProductsWrite)
+	assert.Nil(t, err)
+	assert.Nil(t, access.Grant(ctx, "test", manager, "test_role_manager", "user:*"))
+	assert.Nil(t, access.Grant(ctx, "test", manager, "test_catalog", "product:P001"))
+
+	for _, path := range []string{
+		// grant global butuh roles.manage global
+		"/users/" + target + "/roles/admin",
+		"/users/" + manager + "/roles/admin",
+		// admin punya semua permission, manager tidak
+		"/users/" + manager + "/roles/admin?resource=product:P001",
+		"/users/" + target + "/roles/test_catalog?resource=product:P002",
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, asUser(http.MethodPut, path, "", manager))
+		assert.Equal(t, http.StatusForbidden, recorder.Code, path)
+	}
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, asUser(http.MethodPut, "/users/"+target+"/roles/test_catalog?resource=product:P001", "", manager))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestUserTenantIsolation(t *testing.T) {
+	mux := newTestMux(t)
+
+	id := "http-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+	request := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	request.Header.Set("X-Tenant-ID", "tenant-a")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+	request.Header.Set("X-Tenant-ID", "tenant-b")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+	request.Header.Set("X-Tenant-ID", "tenant-a")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestAdminDeniedInOtherTenant(t *testing.T) {
+	mux := newTestMux(t)
+	db, err := gormapp.OpenDatabase(gormapp.DefaultDSN, logger.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	admin := "admin-" + suffix
+	victim := "victim-" + suffix
+	for id, tenant := range map[string]string{admin: "tenant-a", victim: "tenant-b"} {
+		body := `{"id":"` + id + `","password":"rahasia","name":{"first_name":"Eko"}}`
+		request := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		request.Header.Set("X-Tenant-ID", tenant)
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+	}
+
+	ctx := gormapp.WithTenant(context.Background(), "tenant-a")
+	access := gormapp.NewAccessControl(db)
+	_, err = access.DefineRole(ctx, "admin", gormapp.PermissionAll)
+	assert.Nil(t, err)
+	err = access.Grant(ctx, "test", admin, "admin", "")
+	assert.Nil(t, err)
+
+	// admin tenant A tetap tidak boleh apa apa di tenant B
+	request := asUser(http.MethodPatch, "/users/"+victim, `{"name":{"first_name":"Budi"}}`, admin)
+	request.Header.Set("X-Tenant-ID", "tenant-b")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	request = asUser(http.MethodDelete, "/users/"+victim, "", admin)
+	request.Header.Set("X-Tenant-ID", "tenant-b")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// di tenant nya sendiri role admin berlaku
+	request = asUser(http.MethodGet, "/users/"+admin+"/roles", "", admin)
+	request.Header.Set("X-Tenant-ID", "tenant-a")
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestAdminCannotReachUserInOtherTenant(t *testing.T) {
+	mux := newTestMux(t)
+	db, err := gormapp.OpenDatabase(gormapp.DefaultDSN, logger.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	admin := "admin-" + suffix
+	victim := "victim-" + suffix
+	createTestUser(t, mux, admin, "tenant-a")
+	createTestUser(t, mux, victim, "tenant-b")
+
+	access := gormapp.NewAccessControl(db)
+	tenantA := gormapp.WithTenant(context.Background(), "tenant-a")
+	tenantB := gormapp.WithTenant(context.Background(), "tenant-b")
+	_, err = access.DefineRole(tenantA, "admin", gormapp.PermissionAll)
+	assert.Nil(t, err)
+	assert.Nil(t, access.Grant(tenantA, "test", admin, "admin", ""))
+	assert.Nil(t, access.Grant(tenantB, "test", victim, "admin", ""))
+	// like disisipkan langsung, product P002 ada di tenant default
+	err = db.WithContext(gormapp.WithoutTenant(context.Background())).
+		Exec("INSERT INTO user_like_products (user_id, product_id) VALUES (?, ?)", victim, "P002").Error
+	assert.Nil(t, err)
+
+	// admin anggota tenant A, tapi user di path milik tenant B
+	for _, request := range []*http.Request{
+		asUser(http.MethodGet, "/users/"+victim+"/roles", "", admin),
+		asUser(http.MethodDelete, "/users/"+victim+"/roles/admin", "", admin),
+		asUser(http.MethodDelete, "/users/"+victim+"/liked-products/P002", "", admin),
+	} {
+		request.Header.Set("X-Tenant-ID", "tenant-a")
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, request.Method+" "+request.URL.Path)
+	}
+
+	roles, err := access.Grants(tenantB, victim)
+	assert.Nil(t, err)
+	assert.Len(t, roles, 1)
+	var likes int64
+	err = db.WithContext(gormapp.WithoutTenant(context.Background())).
+		Table("user_like_products").Where("user_id = ?", victim).Count(&likes).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), likes)
+}
+
+func TestRequireTenant(t *testing.T) {
+	handler := requireTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, _ := gormapp.TenantFromContext(r.Context())
+		w.Write([]byte(tenantID))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	request := httptest.NewRequest(http.MethodGet, "/users", nil)
+	request.Header.Set("X-Tenant-ID", "tenant-a")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "tenant-a", recorder.Body.String())
+}
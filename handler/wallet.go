@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+
+	gormapp "belajar_golang_gorm"
+)
+
+type WalletHandler struct {
+	service *gormapp.WalletService
+	auth    *Authorizer
+}
+
+func NewWalletHandler(service *gormapp.WalletService, auth *Authorizer) *WalletHandler {
+	return &WalletHandler{service: service, auth: auth}
+}
+
+// saldo tidak boleh diubah pemilik wallet sendiri, semua perubahan butuh
+// permission wallets.write
+func (h *WalletHandler) Register(mux *http.ServeMux) {
+	user := PathResource("user", "userID")
+	mux.HandleFunc("GET /users/{userID}/wallet", h.Get)
+	mux.HandleFunc("POST /users/{userID}/wallet", h.auth.Require(gormapp.PermissionWalletsWrite, user, h.Create))
+	mux.HandleFunc("PATCH /users/{userID}/wallet", h.auth.Require(gormapp.PermissionWalletsWrite, user, h.Adjust))
+	mux.HandleFunc("DELETE /users/{userID}/wallet", h.auth.Require(gormapp.PermissionWalletsWrite, user, h.Delete))
+}
+
+type createWalletRequest struct {
+	ID      string `json:"id"`
+	Balance int64  `json:"balance"`
+}
+
+// amount positif untuk top up, negatif untuk tarik saldo
+type adjustWalletRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+func (h *WalletHandler) Get(w http.ResponseWriter, r *http.Request) {
+	wallet, err := h.service.Get(r.Context(), r.PathValue("userID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request createWalletRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Balance < 0 {
+		writeServiceError(w, &gormapp.ValidationError{Fields: map[string]string{"balance": "must not be negative"}})
+		return
+	}
+
+	wallet := gormapp.Wallet{ID: request.ID, Balance: request.Balance}
+	if err := h.service.Create(r.Context(), r.PathValue("userID"), &wallet); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, wallet)
+}
+
+func (h *WalletHandler) Adjust(w http.ResponseWriter, r *http.Request) {
+	var request adjustWalletRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	wallet, err := h.service.Adjust(r.Context(), r.PathValue("userID"), request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), r.PathValue("userID")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
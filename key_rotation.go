@@ -0,0 +1,136 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EncryptedColumn kolom dengan serializer:encrypted yang diurus KeyRotationJob
+type EncryptedColumn struct {
+	Table  string
+	Column string
+	// IndexColumn kolom blind index yang ikut diisi, kosong kalau tidak ada
+	IndexColumn string
+	Normalize   func(string) string
+}
+
+// EncryptedColumns semua kolom terenkripsi, tambahkan di sini kalau ada
+// field baru yang pakai serializer:encrypted
+var EncryptedColumns = []EncryptedColumn{
+	{Table: "addresses", Column: "line1"},
+	{Table: "addresses", Column: "line2"},
+	{Table: "guest_books", Column: "email", IndexColumn: "email_index", Normalize: NormalizeEmail},
+}
+
+// KeyRotationJob enkripsi ulang nilai yang masih pakai kunci lama atau
+// belum dienkripsi sama sekali (data dari sebelum kolomnya dienkripsi)
+type KeyRotationJob struct {
+	db        *gorm.DB
+	keyring   *Keyring
+	Columns   []EncryptedColumn
+	BatchSize int
+	// jeda antar putaran waktu jalan sebagai goroutine
+	Interval time.Duration
+}
+
+func NewKeyRotationJob(db *gorm.DB, keyring *Keyring) *KeyRotationJob {
+	return &KeyRotationJob{
+		db:        db,
+		keyring:   keyring,
+		Columns:   EncryptedColumns,
+		BatchSize: 500,
+		Interval:  time.Hour,
+	}
+}
+
+// Run jalankan RunOnce terus menerus sampai ctx dibatalkan
+func (j *KeyRotationJob) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce satu putaran ke semua kolom, return jumlah nilai yang dienkripsi
+// ulang. kunci lama baru boleh dibuang dari keyring setelah hasilnya 0.
+func (j *KeyRotationJob) RunOnce(ctx context.Context) (int, error) {
+	rotated := 0
+	for _, column := range j.Columns {
+		n, err := j.rotateColumn(j.db.WithContext(ctx), column)
+		rotated += n
+		if err != nil {
+			return rotated, err
+		}
+	}
+	return rotated, nil
+}
+
+type encryptedRow struct {
+	ID    int64
+	Value string
+}
+
+// rotateColumn lewat Table() bukan model, jadi serializer, hook dan version
+// tidak ikut jalan. isi kolom secara logika tidak berubah.
+func (j *KeyRotationJob) rotateColumn(db *gorm.DB, column EncryptedColumn) (int, error) {
+	aad := encryptionAAD(column.Table, column.Column)
+	current := encryptedPrefix + j.keyring.current + ":%"
+
+	rotated := 0
+	var lastID int64
+	for {
+		var rows []encryptedRow
+		err := db.Table(column.Table).
+			Select("id", column.Column+" AS value").
+			Where("id > ?", lastID).
+			Where(column.Column+" <> '' AND "+column.Column+" NOT LIKE ?", current).
+			Order("id asc").
+			Limit(j.BatchSize).
+			Scan(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return rotated, err
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			plaintext, err := j.keyring.Decrypt(row.Value, aad)
+			if err != nil {
+				return rotated, err
+			}
+			encrypted, err := j.keyring.Encrypt(plaintext, aad)
+			if err != nil {
+				return rotated, err
+			}
+
+			values := map[string]interface{}{column.Column: encrypted}
+			if column.IndexColumn != "" {
+				values[column.IndexColumn] = j.keyring.BlindIndex(column.Normalize(plaintext))
+			}
+			// kalau nilainya baru saja diubah orang lain, biarkan saja
+			result := db.Table(column.Table).
+				Where("id = ? AND "+column.Column+" = ?", row.ID, row.Value).
+				Updates(values)
+			if result.Error != nil {
+				return rotated, result.Error
+			}
+			rotated += int(result.RowsAffected)
+		}
+	}
+}
+
+// NormalizeEmail bentuk email yang dipakai untuk blind index
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,53 @@
+package belajar_golang_gorm
+
+import "belajar_golang_gorm/filter"
+
+// whitelist field yang boleh dipakai di ?filter, ?sort dan ?fields
+// untuk tiap list endpoint. password sengaja tidak ada.
+
+var (
+	textOperators  = []filter.Operator{filter.Eq, filter.Ne, filter.Like, filter.In}
+	rangeOperators = []filter.Operator{filter.Eq, filter.Gt, filter.Gte, filter.Lt, filter.Lte}
+)
+
+var UserFilterSchema = filter.Schema{
+	Fields: map[string]filter.Field{
+		"id":          {Column: "id", Operators: textOperators, Sortable: true, Selectable: true},
+		"first_name":  {Column: "first_name", Operators: textOperators, Sortable: true, Selectable: true},
+		"middle_name": {Column: "middle_name", Operators: textOperators, Sortable: true, Selectable: true},
+		"last_name":   {Column: "last_name", Operators: textOperators, Sortable: true, Selectable: true},
+		"created_at":  {Column: "created_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+		"updated_at":  {Column: "updated_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+	},
+	AlwaysSelect: []string{"id"},
+}
+
+// line1 dan line2 terenkripsi, filter dan sort tidak ada artinya
+var AddressFilterSchema = filter.Schema{
+	Fields: map[string]filter.Field{
+		"id":               {Column: "id", Operators: []filter.Operator{filter.Eq, filter.In}, Sortable: true, Selectable: true},
+		"label":            {Column: "label", Operators: textOperators, Sortable: true, Selectable: true},
+		"line1":            {Column: "line1", Unfilterable: true, Selectable: true},
+		"line2":            {Column: "line2", Unfilterable: true, Selectable: true},
+		"city":             {Column: "city", Operators: textOperators, Sortable: true, Selectable: true},
+		"region":           {Column: "region", Operators: textOperators, Sortable: true, Selectable: true},
+		"postal_code":      {Column: "postal_code", Operators: textOperators, Sortable: true, Selectable: true},
+		"country_code":     {Column: "country_code", Operators: []filter.Operator{filter.Eq, filter.Ne, filter.In}, Sortable: true, Selectable: true},
+		"default_shipping": {Column: "default_shipping", Operators: []filter.Operator{filter.Eq}, Selectable: true},
+		"default_billing":  {Column: "default_billing", Operators: []filter.Operator{filter.Eq}, Selectable: true},
+		"created_at":       {Column: "created_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+		"updated_at":       {Column: "updated_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+	},
+	AlwaysSelect: []string{"id", "user_id"},
+}
+
+var ProductFilterSchema = filter.Schema{
+	Fields: map[string]filter.Field{
+		"id":         {Column: "id", Operators: textOperators, Sortable: true, Selectable: true},
+		"name":       {Column: "name", Operators: textOperators, Sortable: true, Selectable: true},
+		"price":      {Column: "price", Operators: rangeOperators, Sortable: true, Selectable: true},
+		"created_at": {Column: "created_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+		"updated_at": {Column: "updated_at", Operators: rangeOperators, Sortable: true, Selectable: true},
+	},
+	AlwaysSelect: []string{"id"},
+}
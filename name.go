@@ -0,0 +1,136 @@
+package belajar_golang_gorm
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchNameMaxLength panjang kolom users.search_name
+const SearchNameMaxLength = 255
+
+type Name struct {
+	FirstName  string `gorm:"column:first_name" json:"first_name"`
+	MiddleName string `gorm:"column:middle_name" json:"middle_name"`
+	LastName   string `gorm:"column:last_name" json:"last_name"`
+}
+
+// NameOrder urutan bagian nama waktu ditampilkan
+type NameOrder int
+
+const (
+	// GivenNameFirst "first middle last", dipakai kebanyakan bahasa
+	GivenNameFirst NameOrder = iota
+	// FamilyNameFirst "last first middle", misalnya jepang, cina, korea,
+	// hungaria dan vietnam
+	FamilyNameFirst
+)
+
+var familyNameFirstLanguages = map[string]bool{
+	"ja": true,
+	"zh": true,
+	"ko": true,
+	"hu": true,
+	"vi": true,
+}
+
+// NameOrderFor urutan nama untuk locale BCP 47 seperti "ja-JP", locale
+// kosong atau tidak dikenal dianggap GivenNameFirst
+func NameOrderFor(locale string) NameOrder {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return GivenNameFirst
+	}
+	base, _ := tag.Base()
+	if familyNameFirstLanguages[base.String()] {
+		return FamilyNameFirst
+	}
+	return GivenNameFirst
+}
+
+// parts bagian nama yang tidak kosong sesuai urutan
+func (n Name) parts(order NameOrder) []string {
+	ordered := []string{n.FirstName, n.MiddleName, n.LastName}
+	if order == FamilyNameFirst {
+		ordered = []string{n.LastName, n.FirstName, n.MiddleName}
+	}
+	parts := make([]string, 0, len(ordered))
+	for _, part := range ordered {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Full nama lengkap dengan urutan GivenNameFirst
+func (n Name) Full() string {
+	return n.FullFor("")
+}
+
+// FullFor nama lengkap sesuai urutan nama di locale
+func (n Name) FullFor(locale string) string {
+	return strings.Join(n.parts(NameOrderFor(locale)), " ")
+}
+
+// Initials huruf pertama tiap bagian nama, huruf besar, dengan urutan
+// GivenNameFirst. "Eko Kurniawan Khannedy" jadi "EKK".
+func (n Name) Initials() string {
+	return n.InitialsFor("")
+}
+
+// InitialsFor inisial sesuai urutan nama di locale
+func (n Name) InitialsFor(locale string) string {
+	var initials strings.Builder
+	for _, part := range n.parts(NameOrderFor(locale)) {
+		first, _ := utf8.DecodeRuneInString(part)
+		initials.WriteRune(unicode.ToUpper(first))
+	}
+	return initials.String()
+}
+
+// SearchKey isi kolom search_name, nama lengkap GivenNameFirst yang sudah
+// dinormalisasi dengan NormalizeSearchText
+func (n Name) SearchKey() string {
+	key := NormalizeSearchText(n.Full())
+	if utf8.RuneCountInString(key) > SearchNameMaxLength {
+		key = strings.TrimSpace(string([]rune(key)[:SearchNameMaxLength]))
+	}
+	return key
+}
+
+// huruf yang tidak punya bentuk dekomposisi di unicode jadi tidak hilang
+// aksennya lewat NFD
+var searchTextReplacer = strings.NewReplacer(
+	"ß", "ss",
+	"æ", "ae",
+	"œ", "oe",
+	"ø", "o",
+	"ł", "l",
+	"đ", "d",
+	"ð", "d",
+	"þ", "th",
+	"ı", "i",
+)
+
+// NormalizeSearchText huruf kecil, aksen dibuang dan selain huruf atau angka
+// jadi satu spasi. "  José-María O'Brien " jadi "jose maria o brien".
+// hasilnya tidak pernah berisi % atau _ jadi aman dipakai di LIKE.
+func NormalizeSearchText(text string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(stripAccents, strings.ToLower(text))
+	if err != nil {
+		stripped = strings.ToLower(text)
+	}
+	stripped = searchTextReplacer.Replace(stripped)
+
+	words := strings.FieldsFunc(stripped, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,186 @@
+package belajar_golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrStaleObject = errors.New("stale object")
+
+// Version kolom untuk optimistic locking, dipakai dengan plugin OptimisticLock.
+// setiap Save/Updates menaikkan version dan hanya berhasil kalau version di
+// database masih sama dengan yang terakhir dibaca.
+type Version int64
+
+var versionType = reflect.TypeOf(Version(0))
+
+const (
+	optimisticLockExpected = "optimistic_lock:expected"
+	optimisticLockClauses  = "optimistic_lock:clauses"
+)
+
+// OptimisticLock plugin gorm untuk model yang punya field bertipe Version.
+//
+// version yang dicek diambil dari model yang diupdate, atau dari key
+// "version" kalau update pakai map (misalnya map{"version": 3, "name": ...}
+// berarti yang mengirim terakhir membaca version 3). model dengan version 0
+// dan map tanpa key "version" tidak dicek, version tetap dinaikkan.
+type OptimisticLock struct{}
+
+func (OptimisticLock) Name() string {
+	return "optimistic_lock"
+}
+
+func (OptimisticLock) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("optimistic_lock:create", initialVersion); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("optimistic_lock:before_update", guardVersion); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("optimistic_lock:after_update", checkVersion)
+}
+
+func versionField(stmt *gorm.Statement) *schema.Field {
+	if stmt.Schema == nil {
+		return nil
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == versionType && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// initialVersion row baru mulai dari version 1
+func initialVersion(tx *gorm.DB) {
+	field := versionField(tx.Statement)
+	if tx.Error != nil || field == nil {
+		return
+	}
+
+	setInitial := func(value reflect.Value) {
+		if _, zero := field.ValueOf(tx.Statement.Context, value); zero {
+			tx.AddError(field.Set(tx.Statement.Context, value, Version(1)))
+		}
+	}
+	switch tx.Statement.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < tx.Statement.ReflectValue.Len(); i++ {
+			setInitial(reflect.Indirect(tx.Statement.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		setInitial(tx.Statement.ReflectValue)
+	}
+}
+
+// guardVersion susun SET sendiri supaya bisa ditambah version = version + 1,
+// dan tambah WHERE version = ? kalau version yang diharapkan diketahui
+func guardVersion(tx *gorm.DB) {
+	stmt := tx.Statement
+	field := versionField(stmt)
+	if tx.Error != nil || field == nil || stmt.SQL.Len() > 0 {
+		return
+	}
+
+	var expected interface{}
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		// key version dibuang dari map, nilainya jadi kondisi bukan nilai baru
+		values := make(map[string]interface{}, len(dest))
+		for key, value := range dest {
+			if key == field.DBName || key == field.Name {
+				expected = value
+				continue
+			}
+			values[key] = value
+		}
+		stmt.Dest = values
+	}
+	if expected == nil && stmt.ReflectValue.Kind() == reflect.Struct {
+		if value, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			expected = value
+		}
+	}
+
+	// disimpan untuk dikembalikan setelah update
+	stmt.Settings.Store(optimisticLockClauses, map[string]clause.Clause{
+		"SET":   stmt.Clauses["SET"],
+		"WHERE": stmt.Clauses["WHERE"],
+	})
+
+	set, ok := stmt.Clauses["SET"].Expression.(clause.Set)
+	if !ok {
+		set = callbacks.ConvertToAssignments(stmt)
+		if len(set) == 0 {
+			return
+		}
+	}
+	column := clause.Column{Name: field.DBName}
+	assignments := make(clause.Set, 0, len(set)+1)
+	for _, assignment := range set {
+		if assignment.Column.Name != field.DBName {
+			assignments = append(assignments, assignment)
+		}
+	}
+	assignments = append(assignments, clause.Assignment{Column: column, Value: clause.Expr{SQL: "? + 1", Vars: []interface{}{column}}})
+	stmt.AddClause(assignments)
+
+	if expected != nil {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: expected},
+		}})
+		stmt.Settings.Store(optimisticLockExpected, expected)
+	}
+}
+
+// checkVersion ubah update yang tidak kena row jadi ErrStaleObject dan naikkan
+// version di struct kalau berhasil. SET dan WHERE tambahan dibuang lagi
+// supaya statement yang sama bisa dipakai untuk update berikutnya.
+func checkVersion(tx *gorm.DB) {
+	stmt := tx.Statement
+	field := versionField(stmt)
+	if field == nil {
+		return
+	}
+	if saved, ok := stmt.Settings.LoadAndDelete(optimisticLockClauses); ok {
+		defer func() {
+			for name, saved := range saved.(map[string]clause.Clause) {
+				if saved.Expression == nil {
+					delete(stmt.Clauses, name)
+				} else {
+					stmt.Clauses[name] = saved
+				}
+			}
+		}()
+	}
+
+	expected, guarded := stmt.Settings.LoadAndDelete(optimisticLockExpected)
+	if tx.Error != nil || !guarded {
+		return
+	}
+	if tx.RowsAffected == 0 && !tx.DryRun {
+		tx.AddError(fmt.Errorf("%w: %s version %v", ErrStaleObject, stmt.Table, expected))
+		return
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct && stmt.ReflectValue.CanAddr() {
+		var current int64
+		switch value := expected.(type) {
+		case Version:
+			current = int64(value)
+		default:
+			reflected := reflect.ValueOf(value)
+			if !reflected.CanInt() {
+				return
+			}
+			current = reflected.Int()
+		}
+		tx.AddError(field.Set(stmt.Context, stmt.ReflectValue, Version(current+1)))
+	}
+}
@@ -0,0 +1,251 @@
+// Package pagination keyset (cursor) pagination untuk query gorm.
+//
+// urutan ditentukan oleh daftar Key, key terakhir harus unik (biasanya
+// primary key) supaya tidak ada row yang terlewat atau dobel walaupun data
+// berubah di antara dua halaman. cursor berisi nilai key dari row pertama
+// atau terakhir, di-encode base64 dan ditandatangani HMAC supaya tidak bisa
+// diubah client.
+package pagination
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrNoKeys        = errors.New("pagination needs at least one sort key")
+)
+
+// Key satu kolom urutan, Column adalah nama kolom di tabel model
+type Key struct {
+	Column string
+	Desc   bool
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Signer tanda tangan dan verifikasi cursor
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// NewRandomSigner pakai secret acak, cursor jadi tidak berlaku setelah
+// proses restart atau di instance lain
+func NewRandomSigner() *Signer {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return NewSigner(secret)
+}
+
+type cursor struct {
+	// "next" atau "prev"
+	Direction string            `json:"d"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+func (s *Signer) encode(c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload)), nil
+}
+
+func (s *Signer) decode(text string) (cursor, error) {
+	var c cursor
+	encodedPayload, encodedMAC, ok := strings.Cut(text, ".")
+	if !ok {
+		return c, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Direction != "next" && c.Direction != "prev" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Find ambil satu halaman T dari db (yang boleh sudah berisi Where, Joins,
+// dll) diurutkan sesuai keys. cursor kosong berarti halaman pertama.
+func Find[T any](db *gorm.DB, signer *Signer, keys []Key, cursorText string, limit int) (*Page[T], error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("pagination limit must be positive, got %d", limit)
+	}
+
+	statement := &gorm.Statement{DB: db}
+	if err := statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+
+	backward := false
+	query := db
+	hasCursor := cursorText != ""
+	if hasCursor {
+		c, err := signer.decode(cursorText)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.Values) != len(keys) {
+			return nil, ErrInvalidCursor
+		}
+		values, err := decodeValues(statement, keys, c.Values)
+		if err != nil {
+			return nil, err
+		}
+		backward = c.Direction == "prev"
+		query = query.Where(after(keys, values, backward))
+	}
+
+	for _, key := range keys {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: key.Column},
+			Desc:   key.Desc != backward,
+		})
+	}
+
+	var items []T
+	if err := query.Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	// satu row lebih untuk tahu masih ada halaman di arah yang diminta
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	hasNext, hasPrev := more, hasCursor
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	var err error
+	if hasNext {
+		page.NextCursor, err = encodeCursor(db, signer, statement, keys, items[len(items)-1], "next")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		page.PrevCursor, err = encodeCursor(db, signer, statement, keys, items[0], "prev")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// after bikin kondisi "row sesudah cursor" untuk key gabungan:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+// arah > atau < tergantung Desc tiap key dan arah halaman.
+func after(keys []Key, values []interface{}, backward bool) clause.Expression {
+	var branches []clause.Expression
+	for i, key := range keys {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: column(keys[j]), Value: values[j]})
+		}
+		if key.Desc != backward {
+			and = append(and, clause.Lt{Column: column(key), Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: column(key), Value: values[i]})
+		}
+		branches = append(branches, clause.And(and...))
+	}
+	return clause.Or(branches...)
+}
+
+func column(key Key) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: key.Column}
+}
+
+// decodeValues ubah nilai json di cursor ke tipe field aslinya, supaya
+// misalnya time.Time dikirim ke database sebagai waktu, bukan string
+func decodeValues(statement *gorm.Statement, keys []Key, raw []json.RawMessage) ([]interface{}, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		field := statement.Schema.LookUpField(key.Column)
+		if field == nil {
+			return nil, fmt.Errorf("pagination key %s is not a field of %s", key.Column, statement.Schema.Name)
+		}
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(raw[i], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, nil
+}
+
+func encodeCursor[T any](db *gorm.DB, signer *Signer, statement *gorm.Statement, keys []Key, item T, direction string) (string, error) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	c := cursor{Direction: direction}
+	row := reflect.ValueOf(&item).Elem()
+	for _, key := range keys {
+		field := statement.Schema.LookUpField(key.Column)
+		if field == nil {
+			return "", fmt.Errorf("pagination key %s is not a field of %s", key.Column, statement.Schema.Name)
+		}
+		value, _ := field.ValueOf(ctx, row)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+	return signer.encode(c)
+}
@@ -0,0 +1,64 @@
+package pagination
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type entry struct {
+	ID        int64
+	CreatedAt int64
+}
+
+// dry run, tidak butuh koneksi ke mysql
+func dryRun(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	assert.Nil(t, err)
+	return db
+}
+
+func TestSignerRejectsTamperedCursor(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	text, err := signer.encode(cursor{Direction: "next", Values: []json.RawMessage{json.RawMessage("1")}})
+	assert.Nil(t, err)
+
+	decoded, err := signer.decode(text)
+	assert.Nil(t, err)
+	assert.Equal(t, "next", decoded.Direction)
+
+	_, err = NewSigner([]byte("other")).decode(text)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = signer.decode("x" + text)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = signer.decode("garbage")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestFindCompoundKeys(t *testing.T) {
+	db := dryRun(t)
+	signer := NewSigner([]byte("secret"))
+	keys := []Key{{Column: "created_at", Desc: true}, {Column: "id"}}
+
+	text, err := signer.encode(cursor{Direction: "next", Values: []json.RawMessage{json.RawMessage("10"), json.RawMessage("5")}})
+	assert.Nil(t, err)
+
+	var sql string
+	db.Callback().Query().After("gorm:query").Register("capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	_, err = Find[entry](db, signer, keys, text, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM `entries` WHERE (`entries`.`created_at` < ? OR (`entries`.`created_at` = ? AND `entries`.`id` > ?)) ORDER BY `entries`.`created_at` DESC,`entries`.`id` LIMIT ?", sql)
+
+	_, err = Find[entry](db, signer, nil, "", 2)
+	assert.ErrorIs(t, err, ErrNoKeys)
+}
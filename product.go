@@ -3,12 +3,14 @@ package belajar_golang_gorm
 import "time"
 
 type Product struct {
-	ID           string    `gorm:"primary_key;column:id"`
-	Name         string    `gorm:"column:name"`
-	Price        int64     `gorm:"column:price"`
-	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt    time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
-	LikedByUsers []User    `gorm:"many2many:user_like_products;foreignKey:id;joinForeignKey:product_id;references:id;joinReferences:user_id"`
+	ID           string    `gorm:"primary_key;column:id" json:"id"`
+	TenantID     TenantID  `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	Name         string    `gorm:"column:name" json:"name"`
+	Price        int64     `gorm:"column:price" json:"price"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+	Version      Version   `gorm:"column:version;not null;default:1" json:"version"`
+	LikedByUsers []User    `gorm:"many2many:user_like_products;foreignKey:id;joinForeignKey:product_id;references:id;joinReferences:user_id" json:"liked_by_users,omitempty"`
 }
 
 func (p *Product) TableName() string {
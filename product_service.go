@@ -0,0 +1,130 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+
+	"belajar_golang_gorm/filter"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProductService struct {
+	db *gorm.DB
+}
+
+func NewProductService(db *gorm.DB) *ProductService {
+	return &ProductService{db: db}
+}
+
+// ProductUpdate field yang nil tidak diubah, Version sama seperti di UserUpdate
+type ProductUpdate struct {
+	Name    *string
+	Price   *int64
+	Version *Version
+}
+
+func (s *ProductService) Create(ctx context.Context, product *Product) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(product).Error
+}
+
+func (s *ProductService) Get(ctx context.Context, id string) (*Product, error) {
+	var product Product
+	err := s.db.WithContext(ctx).Take(&product, "id = ?", id).Error
+	if err != nil {
+		return nil, notFound(err, "product", id)
+	}
+	return &product, nil
+}
+
+// List query dari filter.Parse dengan ProductFilterSchema, boleh nil
+func (s *ProductService) List(ctx context.Context, query *filter.Query, limit int, offset int) ([]Product, error) {
+	var products []Product
+	err := s.db.WithContext(ctx).
+		Scopes(query.Scope).
+		Order("id asc").
+		Limit(listLimit(limit)).
+		Offset(offset).
+		Find(&products).Error
+	return products, err
+}
+
+func (s *ProductService) Update(ctx context.Context, id string, update ProductUpdate) (*Product, error) {
+	values := map[string]interface{}{}
+	if update.Name != nil {
+		values["name"] = *update.Name
+	}
+	if update.Price != nil {
+		values["price"] = *update.Price
+	}
+	if update.Version != nil && len(values) > 0 {
+		values["version"] = *update.Version
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Product{}).Where("id = ?", id).Updates(values)
+		if errors.Is(result.Error, ErrStaleObject) {
+			return staleOrNotFound(tx, result.Error, &Product{}, "product", id)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return requireExists(tx, &Product{}, "product", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *ProductService) Delete(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_like_products WHERE product_id = ?", id).Error; err != nil {
+			return err
+		}
+		return deleteOne(tx.Where("id = ?", id), &Product{}, "product", id)
+	})
+}
+
+// LikedProducts produk yang di like user, query sama seperti List
+func (s *ProductService) LikedProducts(ctx context.Context, userID string, query *filter.Query) ([]Product, error) {
+	var products []Product
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		liked := tx.Table("user_like_products").Select("product_id").Where("user_id = ?", userID)
+		return tx.Scopes(query.Scope).Where("id IN (?)", liked).Order("id asc").Find(&products).Error
+	})
+	return products, err
+}
+
+// Like idempotent, like dua kali tetap satu row di user_like_products
+func (s *ProductService) Like(ctx context.Context, userID string, productID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		if err := requireExists(tx, &Product{}, "product", productID); err != nil {
+			return err
+		}
+		return tx.Table("user_like_products").Clauses(clause.OnConflict{DoNothing: true}).Create(map[string]interface{}{
+			"user_id":    userID,
+			"product_id": productID,
+		}).Error
+	})
+}
+
+// Unlike user dicek dulu karena Exec tidak difilter TenantScope
+func (s *ProductService) Unlike(ctx context.Context, userID string, productID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM user_like_products WHERE user_id = ? AND product_id = ?", userID, productID).Error
+	})
+}
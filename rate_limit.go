@@ -0,0 +1,154 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitCounter jumlah hit per key per window, disimpan di database
+// supaya limitnya sama untuk semua instance aplikasi
+type RateLimitCounter struct {
+	BucketKey   string    `gorm:"primary_key;column:bucket_key;size:191"`
+	WindowStart time.Time `gorm:"primary_key;column:window_start"`
+	Hits        int64     `gorm:"column:hits"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index"`
+}
+
+func (c *RateLimitCounter) TableName() string {
+	return "rate_limit_counters"
+}
+
+type RateLimitRule struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+}
+
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s, retry after %s", e.Key, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+type RateLimiter struct {
+	db  *gorm.DB
+	Now func() time.Time
+}
+
+func NewRateLimiter(db *gorm.DB) *RateLimiter {
+	return &RateLimiter{db: db, Now: time.Now}
+}
+
+// Allow catat satu hit untuk semua rule sekaligus. kalau ada rule yang
+// kelewatan tidak ada hit yang dicatat dan return *RateLimitError dengan
+// RetryAfter paling lama.
+//
+// pakai sliding window counter: hit window sebelumnya dihitung sesuai
+// sisa porsinya di window sekarang.
+func (l *RateLimiter) Allow(ctx context.Context, rules ...RateLimitRule) error {
+	// urutkan key supaya lock row selalu berurutan dan tidak deadlock
+	rules = append([]RateLimitRule(nil), rules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Key < rules[j].Key })
+
+	now := l.Now()
+	var limited *RateLimitError
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, rule := range rules {
+			retryAfter, err := l.hit(tx, rule, now)
+			if err != nil {
+				return err
+			}
+			if retryAfter > 0 && (limited == nil || retryAfter > limited.RetryAfter) {
+				limited = &RateLimitError{Key: rule.Key, RetryAfter: retryAfter}
+			}
+		}
+		if limited != nil {
+			return limited
+		}
+		return nil
+	})
+	return err
+}
+
+// hit naikkan counter window sekarang lalu hitung estimasinya, return
+// berapa lama harus menunggu kalau limit terlewati
+func (l *RateLimiter) hit(tx *gorm.DB, rule RateLimitRule, now time.Time) (time.Duration, error) {
+	windowStart := now.Truncate(rule.Window)
+	previousStart := windowStart.Add(-rule.Window)
+
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bucket_key"}, {Name: "window_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"hits": gorm.Expr("hits + 1")}),
+	}).Create(&RateLimitCounter{
+		BucketKey:   rule.Key,
+		WindowStart: windowStart,
+		Hits:        1,
+		ExpiresAt:   windowStart.Add(2 * rule.Window),
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var counters []RateLimitCounter
+	err = tx.Where("bucket_key = ? AND window_start IN ?", rule.Key, []time.Time{previousStart, windowStart}).
+		Find(&counters).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var current, previous float64
+	for _, counter := range counters {
+		if counter.WindowStart.Equal(windowStart) {
+			current = float64(counter.Hits)
+		} else {
+			previous = float64(counter.Hits)
+		}
+	}
+
+	window := rule.Window.Seconds()
+	elapsed := now.Sub(windowStart).Seconds()
+	limit := float64(rule.Limit)
+	if previous*(1-elapsed/window)+current <= limit {
+		return 0, nil
+	}
+
+	// cari kapan hit ini akan diterima, hit yang ditolak tidak ikut dihitung
+	before := current - 1
+	if previous > 0 {
+		wait := window*(1-(limit-before-1)/previous) - elapsed
+		if wait <= window-elapsed {
+			return retryDuration(wait), nil
+		}
+	}
+	nextWindow := 0.0
+	if before > 0 {
+		nextWindow = math.Max(0, window*(1-(limit-1)/before))
+	}
+	return retryDuration(window - elapsed + nextWindow), nil
+}
+
+func retryDuration(seconds float64) time.Duration {
+	return max(time.Second, time.Duration(math.Ceil(seconds))*time.Second)
+}
+
+// Cleanup hapus counter yang sudah tidak dipakai untuk perhitungan
+func (l *RateLimiter) Cleanup(ctx context.Context) (int64, error) {
+	result := l.db.WithContext(ctx).Where("expires_at < ?", l.Now()).Delete(&RateLimitCounter{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,119 @@
+package belajar_golang_gorm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecurrenceFrequency string
+
+const (
+	RecurrenceDaily   RecurrenceFrequency = "daily"
+	RecurrenceWeekly  RecurrenceFrequency = "weekly"
+	RecurrenceMonthly RecurrenceFrequency = "monthly"
+)
+
+// batas pencarian occurrence berikutnya, biar aturan yang tidak pernah
+// match (misal weekly tanpa weekday) tidak loop selamanya
+const recurrenceSearchDays = 5 * 366
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+// TodoRecurrence aturan pengulangan yang ditempel ke todo template
+type TodoRecurrence struct {
+	ID             int64               `gorm:"primary_key;column:id;autoIncrement"`
+	TemplateID     uint                `gorm:"column:template_id;uniqueIndex"`
+	Frequency      RecurrenceFrequency `gorm:"column:frequency;type:varchar(20)"`
+	Weekdays       []time.Weekday      `gorm:"column:weekdays;serializer:json"`
+	DayOfMonth     int                 `gorm:"column:day_of_month"`
+	StartsAt       time.Time           `gorm:"column:starts_at"`
+	EndsAt         *time.Time          `gorm:"column:ends_at"`
+	MaxCount       int                 `gorm:"column:max_count"`
+	GeneratedCount int                 `gorm:"column:generated_count"`
+	NextAt         *time.Time          `gorm:"column:next_at;index"`
+	FinishedAt     *time.Time          `gorm:"column:finished_at"`
+	CreatedAt      time.Time           `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time           `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	Template       Todo                `gorm:"foreignKey:template_id;references:id"`
+}
+
+func (r *TodoRecurrence) TableName() string {
+	return "todo_recurrences"
+}
+
+func (r *TodoRecurrence) Validate() error {
+	switch r.Frequency {
+	case RecurrenceDaily:
+	case RecurrenceWeekly:
+		if len(r.Weekdays) == 0 {
+			return fmt.Errorf("%w: weekly rule needs at least one weekday", ErrInvalidRecurrence)
+		}
+	case RecurrenceMonthly:
+		if r.DayOfMonth < 1 || r.DayOfMonth > 31 {
+			return fmt.Errorf("%w: day of month must be between 1 and 31", ErrInvalidRecurrence)
+		}
+	default:
+		return fmt.Errorf("%w: unknown frequency %s", ErrInvalidRecurrence, r.Frequency)
+	}
+	if r.StartsAt.IsZero() {
+		return fmt.Errorf("%w: start time is required", ErrInvalidRecurrence)
+	}
+	return nil
+}
+
+func (r *TodoRecurrence) BeforeCreate(tx *gorm.DB) error {
+	return r.Validate()
+}
+
+// Exhausted true kalau end date atau jumlah maksimal sudah terlewati
+func (r *TodoRecurrence) Exhausted(occurrence time.Time) bool {
+	if r.EndsAt != nil && occurrence.After(*r.EndsAt) {
+		return true
+	}
+	return r.MaxCount > 0 && r.GeneratedCount >= r.MaxCount
+}
+
+// NextOccurrence cari occurrence pertama yang >= from. jam occurrence
+// selalu mengikuti jam di StartsAt.
+func (r *TodoRecurrence) NextOccurrence(from time.Time) (time.Time, bool) {
+	if from.Before(r.StartsAt) {
+		from = r.StartsAt
+	}
+	from = from.In(r.StartsAt.Location())
+
+	hour, minute, second := r.StartsAt.Clock()
+	year, month, day := from.Date()
+	for i := 0; i < recurrenceSearchDays; i++ {
+		candidate := time.Date(year, month, day+i, hour, minute, second, 0, r.StartsAt.Location())
+		if candidate.Before(from) {
+			continue
+		}
+		if r.matches(candidate) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *TodoRecurrence) matches(date time.Time) bool {
+	switch r.Frequency {
+	case RecurrenceDaily:
+		return true
+	case RecurrenceWeekly:
+		for _, weekday := range r.Weekdays {
+			if date.Weekday() == weekday {
+				return true
+			}
+		}
+		return false
+	case RecurrenceMonthly:
+		// tanggal 31 di bulan yang cuma 30 hari jatuh ke hari terakhir
+		lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day()
+		return date.Day() == min(r.DayOfMonth, lastDay)
+	default:
+		return false
+	}
+}
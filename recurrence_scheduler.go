@@ -0,0 +1,156 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecurrenceScheduler struct {
+	db *gorm.DB
+	// occurrence dibuat sampai sejauh ini ke depan
+	Horizon time.Duration
+	// jeda antar putaran waktu jalan sebagai goroutine
+	Interval time.Duration
+	Now      func() time.Time
+	// dipanggil Run kalau satu putaran gagal, putaran berikutnya tetap jalan
+	OnError func(err error)
+}
+
+func NewRecurrenceScheduler(db *gorm.DB) *RecurrenceScheduler {
+	return &RecurrenceScheduler{
+		db:       db,
+		Horizon:  7 * 24 * time.Hour,
+		Interval: time.Minute,
+		Now:      time.Now,
+		OnError: func(err error) {
+			log.Printf("recurrence scheduler: %v", err)
+		},
+	}
+}
+
+// Run jalankan RunOnce terus menerus sampai ctx dibatalkan. putaran yang
+// gagal (deadlock, koneksi putus) dilaporkan ke OnError lalu dicoba lagi
+// di tick berikutnya.
+func (s *RecurrenceScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil && s.OnError != nil {
+			s.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce buat occurrence yang jatuh tempo sampai Now()+Horizon untuk semua
+// aturan yang masih aktif. aman dijalankan berulang atau dari beberapa
+// instance sekaligus, return jumlah todo yang baru dibuat. aturan yang gagal
+// tidak menghentikan aturan lain, error nya digabung dengan errors.Join.
+func (s *RecurrenceScheduler) RunOnce(ctx context.Context) (int, error) {
+	db := s.db.WithContext(ctx)
+	until := s.Now().Add(s.Horizon)
+
+	// template yang sudah di soft delete tidak ikut karena subquery
+	// Model(&Todo{}) otomatis pakai deleted_at IS NULL
+	var ids []int64
+	err := db.Model(&TodoRecurrence{}).
+		Where("finished_at IS NULL").
+		Where("next_at IS NULL OR next_at <= ?", until).
+		Where("template_id IN (?)", db.Model(&Todo{}).Select("id")).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		n, err := s.materialize(db, id, until)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recurrence %d: %w", id, err))
+			continue
+		}
+		created += n
+	}
+	return created, errors.Join(errs...)
+}
+
+func (s *RecurrenceScheduler) materialize(db *gorm.DB, id int64, until time.Time) (int, error) {
+	created := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var recurrence TodoRecurrence
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Joins("Template").
+			First(&recurrence, "todo_recurrences.id = ?", id).Error
+		if err != nil {
+			return err
+		}
+		// template bisa saja di trash setelah query di RunOnce
+		if recurrence.Template.ID == 0 {
+			return nil
+		}
+
+		from := recurrence.StartsAt
+		if recurrence.NextAt != nil {
+			from = *recurrence.NextAt
+		}
+
+		for {
+			occurrence, ok := recurrence.NextOccurrence(from)
+			if !ok || recurrence.Exhausted(occurrence) {
+				now := s.Now()
+				recurrence.NextAt = nil
+				recurrence.FinishedAt = &now
+				break
+			}
+			if occurrence.After(until) {
+				recurrence.NextAt = &occurrence
+				break
+			}
+
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).
+				Create(recurrence.occurrenceTodo(occurrence))
+			if result.Error != nil {
+				return result.Error
+			}
+			// occurrence yang sudah ada (aturan dijalankan ulang untuk jendela
+			// yang sama) tidak dihitung lagi supaya MaxCount tidak cepat habis
+			if result.RowsAffected > 0 {
+				created += int(result.RowsAffected)
+				recurrence.GeneratedCount++
+			}
+			from = occurrence.Add(time.Second)
+		}
+
+		return tx.Model(&recurrence).Select("next_at", "finished_at", "generated_count").Updates(&recurrence).Error
+	})
+	return created, err
+}
+
+func (r *TodoRecurrence) occurrenceTodo(occurrence time.Time) *Todo {
+	recurrenceID := r.ID
+	return &Todo{
+		TenantID:     r.Template.TenantID,
+		UserID:       r.Template.UserID,
+		Title:        r.Template.Title,
+		Description:  r.Template.Description,
+		Priority:     r.Template.Priority,
+		DueAt:        &occurrence,
+		RecurrenceID: &recurrenceID,
+	}
+}
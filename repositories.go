@@ -0,0 +1,172 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// repository per model, method dasar dari Repository ditambah query khusus
+// model itu. WithTx di tiap repository return tipe repository itu sendiri.
+
+type UserRepository struct {
+	*Repository[User, string]
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{NewRepository[User, string](db, "user")}
+}
+
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{r.Repository.WithTx(tx)}
+}
+
+// FindWithDetails ambil user sekalian wallet dan alamatnya
+func (r *UserRepository) FindWithDetails(ctx context.Context, id string) (*User, error) {
+	return r.FindByID(ctx, id, "Wallet", "Addresses")
+}
+
+type WalletRepository struct {
+	*Repository[Wallet, string]
+}
+
+func NewWalletRepository(db *gorm.DB) *WalletRepository {
+	return &WalletRepository{NewRepository[Wallet, string](db, "wallet")}
+}
+
+func (r *WalletRepository) WithTx(tx *gorm.DB) *WalletRepository {
+	return &WalletRepository{r.Repository.WithTx(tx)}
+}
+
+func (r *WalletRepository) FindByUserID(ctx context.Context, userID string) (*Wallet, error) {
+	var wallet Wallet
+	err := r.db.WithContext(ctx).Take(&wallet, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, notFound(err, "wallet", userID)
+	}
+	return &wallet, nil
+}
+
+// FindByUserIDForUpdate sama dengan FindByUserID tapi row di lock,
+// harus dipanggil dari repository yang dibuat lewat WithTx
+func (r *WalletRepository) FindByUserIDForUpdate(ctx context.Context, userID string) (*Wallet, error) {
+	var wallet Wallet
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Take(&wallet, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, notFound(err, "wallet", userID)
+	}
+	return &wallet, nil
+}
+
+type AddressRepository struct {
+	*Repository[Address, int64]
+}
+
+func NewAddressRepository(db *gorm.DB) *AddressRepository {
+	return &AddressRepository{NewRepository[Address, int64](db, "address")}
+}
+
+func (r *AddressRepository) WithTx(tx *gorm.DB) *AddressRepository {
+	return &AddressRepository{r.Repository.WithTx(tx)}
+}
+
+func (r *AddressRepository) FindByUserID(ctx context.Context, userID string) ([]Address, error) {
+	return r.FindAll(ctx, FindOptions{Scopes: []func(*gorm.DB) *gorm.DB{whereUserID(userID)}})
+}
+
+type ProductRepository struct {
+	*Repository[Product, string]
+}
+
+func NewProductRepository(db *gorm.DB) *ProductRepository {
+	return &ProductRepository{NewRepository[Product, string](db, "product")}
+}
+
+func (r *ProductRepository) WithTx(tx *gorm.DB) *ProductRepository {
+	return &ProductRepository{r.Repository.WithTx(tx)}
+}
+
+// FindLikedBy produk yang di like user
+func (r *ProductRepository) FindLikedBy(ctx context.Context, userID string) ([]Product, error) {
+	var products []Product
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_like_products ON user_like_products.product_id = products.id").
+		Where("user_like_products.user_id = ?", userID).
+		Order("products.id asc").
+		Find(&products).Error
+	return products, err
+}
+
+// FindMostLiked produk dengan like terbanyak dulu, like dari user yang sudah
+// di soft delete tidak dihitung
+func (r *ProductRepository) FindMostLiked(ctx context.Context, limit int) ([]Product, error) {
+	var products []Product
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_like_products ON user_like_products.product_id = products.id").
+		Joins("JOIN users ON users.id = user_like_products.user_id AND users.deleted_at IS NULL").
+		Group("products.id").
+		Order("COUNT(user_like_products.user_id) desc, products.id asc").
+		Limit(listLimit(limit)).
+		Find(&products).Error
+	return products, err
+}
+
+type TodoRepository struct {
+	*Repository[Todo, uint]
+}
+
+func NewTodoRepository(db *gorm.DB) *TodoRepository {
+	return &TodoRepository{NewRepository[Todo, uint](db, "todo")}
+}
+
+func (r *TodoRepository) WithTx(tx *gorm.DB) *TodoRepository {
+	return &TodoRepository{r.Repository.WithTx(tx)}
+}
+
+// FindByUserID todo milik user saja, tanpa todo dari list yang dibagikan
+func (r *TodoRepository) FindByUserID(ctx context.Context, userID string) ([]Todo, error) {
+	return r.FindAll(ctx, FindOptions{Scopes: []func(*gorm.DB) *gorm.DB{whereUserID(userID)}})
+}
+
+func (r *TodoRepository) FindOverdue(ctx context.Context, userID string) ([]Todo, error) {
+	return r.FindAll(ctx, FindOptions{
+		Scopes: []func(*gorm.DB) *gorm.DB{whereUserID(userID), OverdueTodos},
+		Order:  "due_at asc, id asc",
+	})
+}
+
+type GuestBookRepository struct {
+	*Repository[GuestBook, int64]
+}
+
+func NewGuestBookRepository(db *gorm.DB) *GuestBookRepository {
+	return &GuestBookRepository{NewRepository[GuestBook, int64](db, "guest book entry")}
+}
+
+func (r *GuestBookRepository) WithTx(tx *gorm.DB) *GuestBookRepository {
+	return &GuestBookRepository{r.Repository.WithTx(tx)}
+}
+
+// FindByEmail lewat blind index karena kolom email terenkripsi
+func (r *GuestBookRepository) FindByEmail(ctx context.Context, email string) ([]GuestBook, error) {
+	index, err := GuestBookEmailIndex(email)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindAll(ctx, FindOptions{Scopes: []func(*gorm.DB) *gorm.DB{func(db *gorm.DB) *gorm.DB {
+		return db.Where("email_index = ?", index)
+	}}})
+}
+
+func (r *GuestBookRepository) CountByStatus(ctx context.Context, status ModerationStatus) (int64, error) {
+	return r.Count(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ?", status)
+	})
+}
+
+func whereUserID(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", userID)
+	}
+}
@@ -0,0 +1,154 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository operasi dasar untuk satu model dengan primary key kolom id.
+// semua method pakai ctx yang dikirim, dan kalau repository dibuat lewat
+// WithTx semua query jalan di transaksi itu.
+type Repository[T any, ID comparable] struct {
+	db       *gorm.DB
+	resource string
+}
+
+// resource dipakai sebagai nama di NotFoundError, misalnya "user"
+func NewRepository[T any, ID comparable](db *gorm.DB, resource string) *Repository[T, ID] {
+	return &Repository[T, ID]{db: db, resource: resource}
+}
+
+// FindOptions pengaturan FindAll, field yang kosong diabaikan
+type FindOptions struct {
+	Scopes  []func(*gorm.DB) *gorm.DB
+	Preload []string
+	Order   string
+	Limit   int
+	Offset  int
+}
+
+// WithTx repository yang sama tapi semua query lewat tx
+func (r *Repository[T, ID]) WithTx(tx *gorm.DB) *Repository[T, ID] {
+	return &Repository[T, ID]{db: tx, resource: r.resource}
+}
+
+func (r *Repository[T, ID]) FindByID(ctx context.Context, id ID, preload ...string) (*T, error) {
+	var entity T
+	query := r.db.WithContext(ctx)
+	for _, name := range preload {
+		query = query.Preload(name)
+	}
+	if err := query.Take(&entity, "id = ?", id).Error; err != nil {
+		return nil, notFound(err, r.resource, fmt.Sprint(id))
+	}
+	return &entity, nil
+}
+
+// FindAll tanpa Order diurutkan berdasarkan id supaya hasilnya stabil
+func (r *Repository[T, ID]) FindAll(ctx context.Context, options FindOptions) ([]T, error) {
+	query := r.db.WithContext(ctx).Scopes(options.Scopes...)
+	for _, name := range options.Preload {
+		query = query.Preload(name)
+	}
+	if options.Order != "" {
+		query = query.Order(options.Order)
+	} else {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}})
+	}
+	if options.Limit > 0 {
+		query = query.Limit(options.Limit)
+	}
+	if options.Offset > 0 {
+		query = query.Offset(options.Offset)
+	}
+
+	var entities []T
+	err := query.Find(&entities).Error
+	return entities, err
+}
+
+// Create tidak ikut menyimpan relasi, simpan relasi lewat repository masing-masing
+func (r *Repository[T, ID]) Create(ctx context.Context, entity *T) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(entity).Error
+}
+
+func (r *Repository[T, ID]) CreateInBatches(ctx context.Context, entities []T, batchSize int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Omit(clause.Associations).CreateInBatches(&entities, batchSize).Error
+}
+
+// Update simpan kolom yang disebut saja, tanpa columns semua kolom disimpan.
+// nilai kosong ("" atau 0) tetap ditulis kalau kolomnya disebut. model dengan
+// Version bisa gagal dengan ErrStaleObject kalau plugin OptimisticLock dipasang.
+func (r *Repository[T, ID]) Update(ctx context.Context, entity *T, columns ...string) error {
+	query := r.db.WithContext(ctx).Model(entity)
+	if len(columns) > 0 {
+		query = query.Select(columns).Omit(clause.Associations)
+	} else {
+		// created_at tidak ikut, entity bisa saja dibuat tanpa dibaca dulu
+		query = query.Select("*").Omit(clause.Associations, "created_at")
+	}
+
+	result := query.Updates(entity)
+	if result.Error != nil && !errors.Is(result.Error, ErrStaleObject) {
+		return result.Error
+	}
+	if result.Error == nil && result.RowsAffected > 0 {
+		return nil
+	}
+
+	id, err := r.primaryKey(entity)
+	if err != nil {
+		return err
+	}
+	exists, err := r.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &NotFoundError{Resource: r.resource, ID: fmt.Sprint(id)}
+	}
+	return result.Error
+}
+
+func (r *Repository[T, ID]) Delete(ctx context.Context, id ID) error {
+	return deleteOne(r.db.WithContext(ctx).Where("id = ?", id), new(T), r.resource, fmt.Sprint(id))
+}
+
+func (r *Repository[T, ID]) Exists(ctx context.Context, id ID) (bool, error) {
+	var found int
+	err := r.db.WithContext(ctx).Model(new(T)).Select("1").Where("id = ?", id).Limit(1).Scan(&found).Error
+	return found == 1, err
+}
+
+func (r *Repository[T, ID]) Count(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(new(T)).Scopes(scopes...).Count(&count).Error
+	return count, err
+}
+
+// primaryKey ambil nilai kolom id dari entity lewat schema gorm
+func (r *Repository[T, ID]) primaryKey(entity *T) (ID, error) {
+	var id ID
+	statement := &gorm.Statement{DB: r.db}
+	if err := statement.Parse(entity); err != nil {
+		return id, err
+	}
+	field := statement.Schema.LookUpField("id")
+	if field == nil {
+		return id, errors.New(r.resource + " has no id column")
+	}
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	id, ok := value.(ID)
+	if !ok {
+		return id, fmt.Errorf("%s id is %T, not %T", r.resource, value, id)
+	}
+	return id, nil
+}
@@ -0,0 +1,159 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultRetryAttempts  = 3
+	DefaultRetryBaseDelay = 20 * time.Millisecond
+	DefaultRetryMaxDelay  = time.Second
+)
+
+// RetryOptions field yang kosong pakai nilai Default di atas
+type RetryOptions struct {
+	// Name nama call site untuk metrik, misalnya "wallet.adjust"
+	Name        string
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Metrics default DefaultRetryMetrics
+	Metrics *RetryMetrics
+}
+
+// IsTransientError error yang hilang kalau transaksinya diulang dari awal
+func IsTransientError(err error) bool {
+	err = TranslateError(err)
+	return errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockTimeout)
+}
+
+// RetryTransaction jalankan fn di transaksi baru, kalau gagal karena deadlock
+// atau lock timeout transaksinya diulang dengan jeda yang makin lama.
+// fn bisa jalan lebih dari sekali, jadi jangan ada efek di luar database
+// di dalamnya. efek lain (kirim email, publish event) dijalankan setelah
+// RetryTransaction return nil.
+func RetryTransaction(ctx context.Context, db *gorm.DB, options RetryOptions, fn func(tx *gorm.DB) error) error {
+	options = options.withDefaults()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = db.WithContext(ctx).Transaction(fn)
+		if err == nil || !IsTransientError(err) {
+			break
+		}
+		if attempt >= options.MaxAttempts {
+			options.Metrics.finished(options.Name, true)
+			return TranslateError(err)
+		}
+
+		timer := time.NewTimer(options.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			options.Metrics.finished(options.Name, true)
+			return errors.Join(TranslateError(err), ctx.Err())
+		case <-timer.C:
+		}
+		options.Metrics.retried(options.Name)
+	}
+
+	options.Metrics.finished(options.Name, false)
+	return err
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.Name == "" {
+		o.Name = "unnamed"
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultRetryAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultRetryBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = DefaultRetryMaxDelay
+	}
+	if o.Metrics == nil {
+		o.Metrics = DefaultRetryMetrics
+	}
+	return o
+}
+
+// delay exponential backoff dengan jitter, antara setengah sampai penuh
+// supaya transaksi yang bentrok tidak mengulang di waktu yang sama
+func (o RetryOptions) delay(attempt int) time.Duration {
+	delay := o.MaxDelay
+	if shift := attempt - 1; shift < 30 && o.BaseDelay<<shift < o.MaxDelay {
+		delay = o.BaseDelay << shift
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// RetryStats angka per call site. Calls dihitung sekali per RetryTransaction,
+// Retries jumlah pengulangan, Exhausted jumlah yang tetap gagal karena
+// kesempatan habis atau ctx selesai waktu menunggu.
+type RetryStats struct {
+	Name      string
+	Calls     int64
+	Retries   int64
+	Exhausted int64
+}
+
+type RetryMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*RetryStats
+}
+
+var DefaultRetryMetrics = NewRetryMetrics()
+
+func NewRetryMetrics() *RetryMetrics {
+	return &RetryMetrics{stats: map[string]*RetryStats{}}
+}
+
+func (m *RetryMetrics) retried(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(name).Retries++
+}
+
+func (m *RetryMetrics) finished(name string, exhausted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.get(name)
+	stats.Calls++
+	if exhausted {
+		stats.Exhausted++
+	}
+}
+
+// get harus dipanggil sambil memegang mu
+func (m *RetryMetrics) get(name string) *RetryStats {
+	stats, ok := m.stats[name]
+	if !ok {
+		stats = &RetryStats{Name: name}
+		m.stats[name] = stats
+	}
+	return stats
+}
+
+// Snapshot salinan semua angka, urut berdasarkan nama
+func (m *RetryMetrics) Snapshot() []RetryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]RetryStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		snapshot = append(snapshot, *stats)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Name < snapshot[j].Name })
+	return snapshot
+}
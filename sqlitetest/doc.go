@@ -0,0 +1,6 @@
+// Package sqlitetest test untuk jalur sqlite, terpisah dari test di root
+// package yang butuh mysql waktu init. go-sqlite3 cuma compile FTS5 dengan
+// build tag sqlite_fts5:
+//
+//	go test -tags sqlite_fts5 ./sqlitetest
+package sqlitetest
@@ -0,0 +1,86 @@
+//go:build sqlite_fts5
+
+package sqlitetest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	gormapp "belajar_golang_gorm"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func OpenSQLiteConnection(t *testing.T) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "todo_search.db")
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&gormapp.UserLog{}, &gormapp.TodoListMember{}, &gormapp.Todo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTodoSearchSQLite(t *testing.T) {
+	ctx := context.Background()
+	sqliteDB := OpenSQLiteConnection(t)
+	service := gormapp.NewTodoService(sqliteDB)
+
+	assert.Nil(t, gormapp.MigrateTodoSearch(sqliteDB))
+	// migrasi kedua tidak boleh gagal karena table dan trigger sudah ada
+	assert.Nil(t, gormapp.MigrateTodoSearch(sqliteDB))
+
+	often := &gormapp.Todo{Title: "Invoice invoice", Description: "Kirim invoice bulanan"}
+	once := &gormapp.Todo{Title: "Rapat mingguan", Description: "Bahas invoice dan jadwal kantor minggu depan"}
+	other := &gormapp.Todo{Title: "Invoice orang lain", Description: "Bukan punya user 1"}
+	assert.Nil(t, service.Create(ctx, "1", often))
+	assert.Nil(t, service.Create(ctx, "1", once))
+	assert.Nil(t, service.Create(ctx, "2", other))
+
+	// trigger insert, hasil diurutkan bm25 dan todo user lain tidak ikut
+	results, err := service.Search(ctx, "1", "invoice")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, often.ID, results[0].ID)
+	assert.Equal(t, once.ID, results[1].ID)
+	assert.Greater(t, results[0].Score, results[1].Score)
+
+	// trigger update, isi lama hilang dari index dan isi baru bisa dicari
+	_, err = service.Update(ctx, "1", once.ID, map[string]interface{}{
+		"title":       "Belanja",
+		"description": "Beli sayur",
+	})
+	assert.Nil(t, err)
+
+	results, err = service.Search(ctx, "1", "invoice")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, often.ID, results[0].ID)
+
+	results, err = service.Search(ctx, "1", "sayur")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, once.ID, results[0].ID)
+
+	// trigger delete, todo yang dihapus permanen keluar dari index
+	err = sqliteDB.Unscoped().Delete(&gormapp.Todo{}, often.ID).Error
+	assert.Nil(t, err)
+
+	results, err = service.Search(ctx, "1", "invoice")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(results))
+
+	var indexed int64
+	err = sqliteDB.Raw("SELECT COUNT(*) FROM todos_fts WHERE todos_fts MATCH ?", `"invoice"`).Scan(&indexed).Error
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), indexed)
+}
@@ -0,0 +1,63 @@
+package belajar_golang_gorm
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Tag struct {
+	ID        int64     `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	Name      string    `gorm:"column:name;size:100;uniqueIndex" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	Todos     []Todo    `gorm:"many2many:todo_tags;foreignKey:id;joinForeignKey:tag_id;references:id;joinReferences:todo_id" json:"-"`
+}
+
+func (t *Tag) TableName() string {
+	return "tags"
+}
+
+// nama tag disimpan lowercase supaya "Work" dan "work" jadi satu tag
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// TodosWithAnyTag filter todo yang punya minimal satu dari tag yang diberikan
+func TodosWithAnyTag(names ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("todos.id IN (?)", todoTagSubQuery(db, names))
+	}
+}
+
+// TodosWithAllTags filter todo yang punya semua tag yang diberikan
+func TodosWithAllTags(names ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		subQuery := todoTagSubQuery(db, names).
+			Group("todo_tags.todo_id").
+			Having("COUNT(DISTINCT tags.id) = ?", len(uniqueTagNames(names)))
+		return db.Where("todos.id IN (?)", subQuery)
+	}
+}
+
+func todoTagSubQuery(db *gorm.DB, names []string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).
+		Table("todo_tags").
+		Select("todo_tags.todo_id").
+		Joins("JOIN tags ON tags.id = todo_tags.tag_id").
+		Where("tags.name IN ?", uniqueTagNames(names))
+}
+
+func uniqueTagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result
+}
@@ -0,0 +1,316 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrMissingTenant  = errors.New("query without tenant")
+	ErrTenantMismatch = errors.New("tenant mismatch")
+)
+
+// TenantID kolom tenant_id, dipakai dengan plugin TenantScope. field nya
+// pasang `<-:create` supaya row tidak bisa dipindah ke tenant lain lewat update.
+type TenantID string
+
+var tenantIDType = reflect.TypeOf(TenantID(""))
+
+type tenantContextKey struct{}
+
+// tenantContext isi context, all true untuk WithoutTenant
+type tenantContext struct {
+	id  TenantID
+	all bool
+}
+
+// WithTenant semua query lewat db.WithContext(ctx) cuma melihat dan menulis
+// row milik tenant ini
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantContext{id: TenantID(tenantID)})
+}
+
+// WithoutTenant sengaja lintas tenant, untuk migrasi dan job background.
+// query tidak difilter dan row baru harus sudah diisi TenantID nya.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantContext{all: true})
+}
+
+// TenantFromContext tenant dari WithTenant, false kalau tidak ada atau
+// context dari WithoutTenant
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(tenantContext)
+	if !ok || tenant.all || tenant.id == "" {
+		return "", false
+	}
+	return string(tenant.id), true
+}
+
+// TenantScope plugin gorm untuk model yang punya field bertipe TenantID.
+//
+// tenant diambil dari context (WithTenant). query, count, scan, update dan
+// delete ditambah WHERE tenant_id = ?, termasuk query Preload dan ON dari
+// Joins relasi. create mengisi TenantID dan ditolak kalau model sudah berisi
+// tenant lain. statement tanpa tenant gagal dengan ErrMissingTenant kecuali
+// context nya dari WithoutTenant.
+//
+// Raw, Exec, Table("nama") tanpa model dan Joins dengan SQL tulisan sendiri
+// tidak difilter, kondisi tenant nya harus ditulis sendiri.
+type TenantScope struct{}
+
+func (TenantScope) Name() string {
+	return "tenant_scope"
+}
+
+func (TenantScope) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tenant_scope:create", assignTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant_scope:query", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant_scope:row", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant_scope:update", scopeTenantWrite); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("tenant_scope:delete", scopeTenantWrite)
+}
+
+func tenantField(s *schema.Schema) *schema.Field {
+	if s == nil {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.FieldType == tenantIDType && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// statementTenant tenant dari context statement, error kalau tidak ada
+func statementTenant(stmt *gorm.Statement) (tenantContext, error) {
+	ctx := stmt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tenant, ok := ctx.Value(tenantContextKey{}).(tenantContext)
+	if !ok || (!tenant.all && tenant.id == "") {
+		return tenant, fmt.Errorf("%w: %s", ErrMissingTenant, stmt.Table)
+	}
+	return tenant, nil
+}
+
+// assignTenant isi tenant_id row baru dari context
+func assignTenant(tx *gorm.DB) {
+	stmt := tx.Statement
+	field := tenantField(stmt.Schema)
+	if tx.Error != nil || field == nil {
+		return
+	}
+	tenant, err := statementTenant(stmt)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+
+	assign := func(value reflect.Value) {
+		current, _ := field.ValueOf(stmt.Context, value)
+		currentID, _ := current.(TenantID)
+		switch {
+		case tenant.all && currentID == "":
+			tx.AddError(fmt.Errorf("%w: %s created without tenant_id", ErrMissingTenant, stmt.Table))
+		case tenant.all:
+		case currentID != "" && currentID != tenant.id:
+			tx.AddError(fmt.Errorf("%w: %s belongs to %s, not %s", ErrTenantMismatch, stmt.Table, currentID, tenant.id))
+		default:
+			tx.AddError(field.Set(stmt.Context, value, tenant.id))
+		}
+	}
+	assignMap := func(values map[string]interface{}) {
+		if tenant.all {
+			if _, ok := values[field.DBName]; !ok {
+				tx.AddError(fmt.Errorf("%w: %s created without tenant_id", ErrMissingTenant, stmt.Table))
+			}
+			return
+		}
+		values[field.DBName] = tenant.id
+	}
+
+	switch values := stmt.Dest.(type) {
+	case map[string]interface{}:
+		assignMap(values)
+		return
+	case []map[string]interface{}:
+		for _, row := range values {
+			assignMap(row)
+		}
+		return
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			assign(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		assign(stmt.ReflectValue)
+	}
+}
+
+// scopeTenant tambah WHERE tenant_id = ? dan kondisi yang sama di ON untuk
+// Joins relasi ke model yang punya tenant
+func scopeTenant(tx *gorm.DB) {
+	stmt := tx.Statement
+	field := tenantField(stmt.Schema)
+	if tx.Error != nil || field == nil || stmt.SQL.Len() > 0 {
+		return
+	}
+	tenant, err := statementTenant(stmt)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	if tenant.all {
+		return
+	}
+
+	// a OR b harus dibungkus dulu, kalau tidak jadi a OR b AND tenant_id = ?
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		for _, expr := range where.Exprs {
+			if _, isOr := expr.(clause.OrConditions); isOr {
+				where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+				c := stmt.Clauses["WHERE"]
+				c.Expression = where
+				stmt.Clauses["WHERE"] = c
+				break
+			}
+		}
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant.id},
+	}})
+
+	for i := range stmt.Joins {
+		join := &stmt.Joins[i]
+		relations, ok := joinRelations(stmt.Schema, join.Name)
+		if !ok {
+			continue
+		}
+		// ON join dipakai untuk setiap tabel di join bertingkat seperti
+		// "User.Wallet", jadi semuanya harus punya tenant_id
+		var joinField *schema.Field
+		for _, relation := range relations {
+			if joinField = tenantField(relation.FieldSchema); joinField == nil {
+				break
+			}
+		}
+		if joinField == nil {
+			continue
+		}
+		on := clause.Where{}
+		if join.On != nil {
+			on = *join.On
+		}
+		on.Exprs = append(on.Exprs, clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: joinField.DBName},
+			Value:  tenant.id,
+		})
+		join.On = &on
+	}
+}
+
+// scopeTenantWrite WHERE tenant_id membuat gorm tidak lagi menolak update
+// atau delete tanpa kondisi, jadi dicek di sini dulu
+func scopeTenantWrite(tx *gorm.DB) {
+	stmt := tx.Statement
+	if tx.Error == nil && tenantField(stmt.Schema) != nil && !tx.AllowGlobalUpdate && !hasConditions(stmt) {
+		tx.AddError(gorm.ErrMissingWhereClause)
+		return
+	}
+	scopeTenant(tx)
+}
+
+// hasConditions ada WHERE atau primary key dari model/dest yang nanti
+// dijadikan kondisi oleh gorm
+func hasConditions(stmt *gorm.Statement) bool {
+	if _, ok := stmt.Clauses["WHERE"]; ok {
+		return true
+	}
+	for _, value := range []reflect.Value{stmt.ReflectValue, reflect.Indirect(reflect.ValueOf(stmt.Dest))} {
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array:
+			if value.Len() > 0 {
+				return true
+			}
+		case reflect.Struct:
+			if value.Type() != stmt.Schema.ModelType {
+				continue
+			}
+			for _, field := range stmt.Schema.PrimaryFields {
+				if _, zero := field.ValueOf(stmt.Context, value); !zero {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// joinRelations relasi dari nama Joins, false untuk join SQL biasa
+func joinRelations(s *schema.Schema, name string) ([]*schema.Relationship, bool) {
+	var relations []*schema.Relationship
+	current := s.Relationships.Relations
+	for _, part := range strings.Split(name, ".") {
+		relation, ok := current[part]
+		if !ok {
+			return nil, false
+		}
+		relations = append(relations, relation)
+		current = relation.FieldSchema.Relationships.Relations
+	}
+	return relations, true
+}
+
+// TenantModels model yang punya kolom tenant_id
+var TenantModels = []interface{}{&User{}, &Wallet{}, &Address{}, &Product{}, &Todo{}, &GuestBook{}}
+
+// MigrateTenants tambah kolom tenant_id dan indexnya di semua TenantModels,
+// row lama dimasukkan ke defaultTenant. AutoMigrate tidak dipakai supaya
+// tipe kolom lain di tabel yang dibuat manual tidak ikut diubah.
+func MigrateTenants(db *gorm.DB, defaultTenant string) error {
+	if defaultTenant == "" {
+		return fmt.Errorf("%w: default tenant is required", ErrMissingTenant)
+	}
+	migrator := db.Migrator()
+	for _, model := range TenantModels {
+		if !migrator.HasColumn(model, "TenantID") {
+			if err := migrator.AddColumn(model, "TenantID"); err != nil {
+				return err
+			}
+		}
+		if !migrator.HasIndex(model, "TenantID") {
+			if err := migrator.CreateIndex(model, "TenantID"); err != nil {
+				return err
+			}
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		err := db.Exec("UPDATE "+stmt.Schema.Table+" SET tenant_id = ? WHERE tenant_id IS NULL OR tenant_id = ''", defaultTenant).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,17 +1,127 @@
 package belajar_golang_gorm
 
 import (
+	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+type TodoStatus string
+
+const (
+	TodoStatusOpen       TodoStatus = "open"
+	TodoStatusInProgress TodoStatus = "in_progress"
+	TodoStatusDone       TodoStatus = "done"
+	TodoStatusArchived   TodoStatus = "archived"
+)
+
+// status tujuan yang boleh dari tiap status
+var todoTransitions = map[TodoStatus][]TodoStatus{
+	TodoStatusOpen:       {TodoStatusInProgress, TodoStatusDone, TodoStatusArchived},
+	TodoStatusInProgress: {TodoStatusOpen, TodoStatusDone, TodoStatusArchived},
+	TodoStatusDone:       {TodoStatusOpen, TodoStatusArchived},
+	TodoStatusArchived:   {TodoStatusOpen},
+}
+
+var ErrInvalidTodoStatus = errors.New("invalid todo status")
+
+type TodoPriority int
+
+const (
+	TodoPriorityLow    TodoPriority = 1
+	TodoPriorityNormal TodoPriority = 2
+	TodoPriorityHigh   TodoPriority = 3
+)
+
 type Todo struct {
 	gorm.Model
-	UserID      string         `gorm:"column:user_id"`
-	Title       string         `gorm:"column:title"`
-	Description string         `gorm:"column:description"`
+	TenantID     TenantID     `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	UserID       string       `gorm:"column:user_id;size:100;index" json:"user_id"`
+	Title        string       `gorm:"column:title" json:"title"`
+	Description  string       `gorm:"column:description" json:"description"`
+	Status       TodoStatus   `gorm:"column:status;type:varchar(20);default:open;index" json:"status"`
+	Priority     TodoPriority `gorm:"column:priority;default:2" json:"priority"`
+	DueAt        *time.Time   `gorm:"column:due_at;index;uniqueIndex:idx_todos_occurrence,priority:2" json:"due_at"`
+	CompletedAt  *time.Time   `gorm:"column:completed_at" json:"completed_at"`
+	RecurrenceID *int64       `gorm:"column:recurrence_id;uniqueIndex:idx_todos_occurrence,priority:1" json:"recurrence_id"`
+	ListID       *int64       `gorm:"column:list_id;index" json:"list_id"`
+	User         User         `gorm:"foreignKey:user_id;references:id" json:"-"`
+	Tags         []Tag        `gorm:"many2many:todo_tags;foreignKey:id;joinForeignKey:todo_id;references:id;joinReferences:tag_id" json:"tags,omitempty"`
 }
 
 func (t *Todo) TableName() string {
 	return "todos"
 }
+
+func (s TodoStatus) Valid() bool {
+	_, ok := todoTransitions[s]
+	return ok
+}
+
+// CanTransitionTo cek apakah perpindahan status diperbolehkan
+func (s TodoStatus) CanTransitionTo(next TodoStatus) bool {
+	for _, allowed := range todoTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo ganti status todo, error kalau perpindahannya tidak diperbolehkan
+func (t *Todo) TransitionTo(next TodoStatus) error {
+	current := t.Status
+	if current == "" {
+		current = TodoStatusOpen
+	}
+	if !next.Valid() || !current.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTodoStatus, current, next)
+	}
+	t.Status = next
+	return nil
+}
+
+func (t *Todo) BeforeSave(tx *gorm.DB) error {
+	if t.Status == "" {
+		t.Status = TodoStatusOpen
+	}
+	if !t.Status.Valid() {
+		return fmt.Errorf("%w: %s", ErrInvalidTodoStatus, t.Status)
+	}
+	if t.Priority == 0 {
+		t.Priority = TodoPriorityNormal
+	}
+
+	// completed_at otomatis ikut status done
+	if t.Status == TodoStatusDone && t.CompletedAt == nil {
+		now := time.Now()
+		t.CompletedAt = &now
+	} else if t.Status != TodoStatusDone && t.Status != TodoStatusArchived {
+		t.CompletedAt = nil
+	}
+
+	return nil
+}
+
+func OverdueTodos(db *gorm.DB) *gorm.DB {
+	return db.Where("due_at < ? AND status IN ?", time.Now(), []TodoStatus{TodoStatusOpen, TodoStatusInProgress})
+}
+
+func DueTodayTodos(db *gorm.DB) *gorm.DB {
+	start := startOfDay(time.Now())
+	return db.Where("due_at >= ? AND due_at < ?", start, start.AddDate(0, 0, 1))
+}
+
+// minggu dihitung mulai hari senin
+func CompletedThisWeekTodos(db *gorm.DB) *gorm.DB {
+	start := startOfDay(time.Now())
+	start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	return db.Where("status = ? AND completed_at >= ?", TodoStatusDone, start)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
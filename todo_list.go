@@ -0,0 +1,115 @@
+package belajar_golang_gorm
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ListRole string
+
+const (
+	ListRoleViewer ListRole = "viewer"
+	ListRoleEditor ListRole = "editor"
+	ListRoleOwner  ListRole = "owner"
+)
+
+var listRoleRanks = map[ListRole]int{
+	ListRoleViewer: 1,
+	ListRoleEditor: 2,
+	ListRoleOwner:  3,
+}
+
+func (r ListRole) Valid() bool {
+	_, ok := listRoleRanks[r]
+	return ok
+}
+
+// AtLeast true kalau role r sama atau lebih tinggi dari min,
+// role kosong berarti bukan member
+func (r ListRole) AtLeast(min ListRole) bool {
+	return r != "" && listRoleRanks[r] >= listRoleRanks[min]
+}
+
+type MembershipStatus string
+
+const (
+	MembershipPending  MembershipStatus = "pending"
+	MembershipAccepted MembershipStatus = "accepted"
+	MembershipDeclined MembershipStatus = "declined"
+)
+
+type TodoList struct {
+	ID        int64            `gorm:"primary_key;column:id;autoIncrement"`
+	Name      string           `gorm:"column:name"`
+	OwnerID   string           `gorm:"column:owner_id;size:100;index"`
+	CreatedAt time.Time        `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time        `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	Owner     User             `gorm:"foreignKey:owner_id;references:id"`
+	Members   []TodoListMember `gorm:"foreignKey:list_id;references:id"`
+	Todos     []Todo           `gorm:"foreignKey:list_id;references:id"`
+}
+
+func (l *TodoList) TableName() string {
+	return "todo_lists"
+}
+
+type TodoListMember struct {
+	ListID    int64            `gorm:"primary_key;column:list_id;autoIncrement:false"`
+	UserID    string           `gorm:"primary_key;column:user_id;size:100"`
+	Role      ListRole         `gorm:"column:role;type:varchar(20)"`
+	Status    MembershipStatus `gorm:"column:status;type:varchar(20);index"`
+	InvitedBy string           `gorm:"column:invited_by;size:100"`
+	CreatedAt time.Time        `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time        `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	List      *TodoList        `gorm:"foreignKey:list_id;references:id"`
+	User      *User            `gorm:"foreignKey:user_id;references:id"`
+}
+
+func (m *TodoListMember) TableName() string {
+	return "todo_list_members"
+}
+
+type TodoListActivity struct {
+	ID        int64     `gorm:"primary_key;column:id;autoIncrement"`
+	ListID    int64     `gorm:"column:list_id;index"`
+	UserID    string    `gorm:"column:user_id;size:100"`
+	TodoID    *uint     `gorm:"column:todo_id"`
+	Action    string    `gorm:"column:action"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (a *TodoListActivity) TableName() string {
+	return "todo_list_activities"
+}
+
+// VisibleTodos filter todo milik user sendiri atau yang ada di list
+// dimana user sudah jadi member
+func VisibleTodos(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		memberLists := db.Session(&gorm.Session{NewDB: true}).
+			Model(&TodoListMember{}).
+			Select("list_id").
+			Where("user_id = ? AND status = ?", userID, MembershipAccepted)
+		return db.Where("(todos.user_id = ? OR todos.list_id IN (?))", userID, memberLists)
+	}
+}
+
+// memberRole role user yang sudah accept di list, kosong kalau bukan member
+func memberRole(tx *gorm.DB, listID int64, userID string) (ListRole, error) {
+	var member TodoListMember
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Where("list_id = ? AND user_id = ? AND status = ?", listID, userID, MembershipAccepted).
+		Limit(1).
+		Find(&member).Error
+	return member.Role, err
+}
+
+func recordListActivity(tx *gorm.DB, listID int64, userID string, todoID *uint, action string) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&TodoListActivity{
+		ListID: listID,
+		UserID: userID,
+		TodoID: todoID,
+		Action: action,
+	}).Error
+}
@@ -0,0 +1,196 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+type TodoListService struct {
+	db *gorm.DB
+}
+
+func NewTodoListService(db *gorm.DB) *TodoListService {
+	return &TodoListService{db: db}
+}
+
+// CreateList buat list baru, pembuatnya otomatis jadi owner
+func (s *TodoListService) CreateList(ctx context.Context, ownerID string, name string) (*TodoList, error) {
+	list := TodoList{
+		Name:    name,
+		OwnerID: ownerID,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&list).Error; err != nil {
+			return err
+		}
+		err := tx.Omit(clause.Associations).Create(&TodoListMember{
+			ListID:    list.ID,
+			UserID:    ownerID,
+			Role:      ListRoleOwner,
+			Status:    MembershipAccepted,
+			InvitedBy: ownerID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return recordListActivity(tx, list.ID, ownerID, nil, "create list")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Lists ambil semua list yang user sudah jadi member
+func (s *TodoListService) Lists(ctx context.Context, userID string) ([]TodoList, error) {
+	db := s.db.WithContext(ctx)
+	memberLists := db.Model(&TodoListMember{}).
+		Select("list_id").
+		Where("user_id = ? AND status = ?", userID, MembershipAccepted)
+
+	var lists []TodoList
+	err := db.Where("id IN (?)", memberLists).
+		Order("id asc").
+		Find(&lists).Error
+	return lists, err
+}
+
+func (s *TodoListService) Todos(ctx context.Context, userID string, listID int64) ([]Todo, error) {
+	var todos []Todo
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireListRole(tx, listID, userID, ListRoleViewer); err != nil {
+			return err
+		}
+		return tx.Where("list_id = ?", listID).Order("id asc").Find(&todos).Error
+	})
+	return todos, err
+}
+
+// Invite undang user ke list dengan role tertentu, cuma owner yang boleh.
+// undangan yang pernah ditolak bisa dikirim ulang.
+func (s *TodoListService) Invite(ctx context.Context, actorID string, listID int64, userID string, role ListRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("invalid list role %q", role)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireListRole(tx, listID, actorID, ListRoleOwner); err != nil {
+			return err
+		}
+
+		var member TodoListMember
+		err := tx.Where("list_id = ? AND user_id = ?", listID, userID).Limit(1).Find(&member).Error
+		if err != nil {
+			return err
+		}
+		if member.Status == MembershipPending || member.Status == MembershipAccepted {
+			return fmt.Errorf("user %s is already invited to list %d", userID, listID)
+		}
+
+		member = TodoListMember{
+			ListID:    listID,
+			UserID:    userID,
+			Role:      role,
+			Status:    MembershipPending,
+			InvitedBy: actorID,
+		}
+		if err := tx.Omit(clause.Associations).Save(&member).Error; err != nil {
+			return err
+		}
+		return recordListActivity(tx, listID, actorID, nil, fmt.Sprintf("invite %s as %s", userID, role))
+	})
+}
+
+// Invitations undangan yang masih menunggu jawaban user
+func (s *TodoListService) Invitations(ctx context.Context, userID string) ([]TodoListMember, error) {
+	var invitations []TodoListMember
+	err := s.db.WithContext(ctx).
+		Preload("List").
+		Where("user_id = ? AND status = ?", userID, MembershipPending).
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (s *TodoListService) AcceptInvitation(ctx context.Context, userID string, listID int64) error {
+	return s.answerInvitation(ctx, userID, listID, MembershipAccepted)
+}
+
+func (s *TodoListService) DeclineInvitation(ctx context.Context, userID string, listID int64) error {
+	return s.answerInvitation(ctx, userID, listID, MembershipDeclined)
+}
+
+func (s *TodoListService) answerInvitation(ctx context.Context, userID string, listID int64, status MembershipStatus) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&TodoListMember{}).
+			Where("list_id = ? AND user_id = ? AND status = ?", listID, userID, MembershipPending).
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotFound
+		}
+		return recordListActivity(tx, listID, userID, nil, string(status)+" invitation")
+	})
+}
+
+// RemoveMember keluarkan user dari list, owner list tidak bisa dikeluarkan
+func (s *TodoListService) RemoveMember(ctx context.Context, actorID string, listID int64, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		list, err := requireListRole(tx, listID, actorID, ListRoleOwner)
+		if err != nil {
+			return err
+		}
+		if list.OwnerID == userID {
+			return fmt.Errorf("%w: cannot remove the list owner", ErrPermissionDenied)
+		}
+		err = tx.Where("list_id = ? AND user_id = ?", listID, userID).Delete(&TodoListMember{}).Error
+		if err != nil {
+			return err
+		}
+		return recordListActivity(tx, listID, actorID, nil, "remove "+userID)
+	})
+}
+
+// Activity riwayat aksi di list, terbaru dulu
+func (s *TodoListService) Activity(ctx context.Context, userID string, listID int64, limit int) ([]TodoListActivity, error) {
+	var activities []TodoListActivity
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := requireListRole(tx, listID, userID, ListRoleViewer); err != nil {
+			return err
+		}
+		return tx.Where("list_id = ?", listID).Order("id desc").Limit(limit).Find(&activities).Error
+	})
+	return activities, err
+}
+
+// requireListRole pastikan user punya minimal role need di list. bukan
+// member sama sekali dianggap list tidak ada.
+func requireListRole(tx *gorm.DB, listID int64, userID string, need ListRole) (*TodoList, error) {
+	role, err := memberRole(tx, listID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, todoListNotFound(listID)
+	}
+	if !role.AtLeast(need) {
+		return nil, ErrPermissionDenied
+	}
+
+	var list TodoList
+	if err := tx.Session(&gorm.Session{NewDB: true}).First(&list, "id = ?", listID).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func todoListNotFound(id int64) error {
+	return &NotFoundError{Resource: "todo list", ID: strconv.FormatInt(id, 10)}
+}
@@ -0,0 +1,96 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrSearchUnsupported = errors.New("todo search is not supported for this database")
+
+const (
+	todoFullTextIndex = "idx_todos_fulltext"
+	todoFTSTable      = "todos_fts"
+)
+
+type TodoSearchResult struct {
+	Todo
+	Score float64 `gorm:"column:score"`
+}
+
+// MigrateTodoSearch siapkan index pencarian sesuai database,
+// mysql pakai FULLTEXT index, sqlite pakai virtual table FTS5 + trigger.
+// go-sqlite3 cuma compile FTS5 dengan build tag sqlite_fts5
+// (go build -tags sqlite_fts5), tanpa tag itu migrasi sqlite gagal
+// dengan "no such module: fts5"
+func MigrateTodoSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "mysql":
+		if db.Migrator().HasIndex(&Todo{}, todoFullTextIndex) {
+			return nil
+		}
+		return db.Exec("ALTER TABLE todos ADD FULLTEXT INDEX " + todoFullTextIndex + " (title, description)").Error
+	case "sqlite":
+		statements := []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS todos_fts USING fts5(title, description, content='todos', content_rowid='id')`,
+			`CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+				INSERT INTO todos_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+				INSERT INTO todos_fts(todos_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE ON todos BEGIN
+				INSERT INTO todos_fts(todos_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+				INSERT INTO todos_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`INSERT INTO todos_fts(todos_fts) VALUES ('rebuild')`,
+		}
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrSearchUnsupported
+	}
+}
+
+// Search cari todo yang bisa dilihat user di title dan description, hasil
+// diurutkan dari yang paling relevan
+func (s *TodoService) Search(ctx context.Context, userID string, query string) ([]TodoSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	db := s.db.WithContext(ctx).Model(&Todo{}).Scopes(VisibleTodos(userID))
+
+	var results []TodoSearchResult
+	switch db.Dialector.Name() {
+	case "mysql":
+		match := "MATCH(todos.title, todos.description) AGAINST (? IN NATURAL LANGUAGE MODE)"
+		db = db.Select("todos.*, "+match+" AS score", query).
+			Where(match, query)
+	case "sqlite":
+		db = db.Select("todos.*, -bm25(todos_fts) AS score").
+			Joins("JOIN todos_fts ON todos_fts.rowid = todos.id").
+			Where("todos_fts MATCH ?", ftsQuery(query))
+	default:
+		return nil, ErrSearchUnsupported
+	}
+
+	err := db.Order("score desc").Scan(&results).Error
+	return results, err
+}
+
+// tiap kata di quote supaya karakter khusus fts5 tidak dianggap operator
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " OR ")
+}
@@ -0,0 +1,301 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jumlah todo yang di hard delete per transaksi waktu purge
+const TodoPurgeBatchSize = 100
+
+type TodoService struct {
+	db *gorm.DB
+}
+
+func NewTodoService(db *gorm.DB) *TodoService {
+	return &TodoService{db: db}
+}
+
+// Get ambil todo yang boleh dilihat user, todo yang tidak boleh dilihat
+// dianggap tidak ada
+func (s *TodoService) Get(ctx context.Context, userID string, id uint) (*Todo, error) {
+	return findTodo(s.db.WithContext(ctx), userID, id, ListRoleViewer)
+}
+
+// List ambil todo milik user sendiri dan todo di list yang dia ikuti
+func (s *TodoService) List(ctx context.Context, userID string) ([]Todo, error) {
+	var todos []Todo
+	err := s.db.WithContext(ctx).Scopes(VisibleTodos(userID)).Order("todos.id asc").Find(&todos).Error
+	return todos, err
+}
+
+// Create selalu pakai userID dari parameter, bukan dari todo yang dikirim.
+// kalau ListID diisi user minimal harus editor di list itu.
+func (s *TodoService) Create(ctx context.Context, userID string, todo *Todo) error {
+	todo.UserID = userID
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if todo.ListID != nil {
+			if _, err := requireListRole(tx, *todo.ListID, userID, ListRoleEditor); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("User").Create(todo).Error; err != nil {
+			return err
+		}
+		return logTodo(tx, userID, todo, "create")
+	})
+}
+
+// Update cuma boleh ubah kolom konten, status lewat SetStatus
+func (s *TodoService) Update(ctx context.Context, userID string, id uint, fields map[string]interface{}) (*Todo, error) {
+	var todo *Todo
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		todo, err = findTodo(tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(todo).Select("title", "description", "priority", "due_at").Updates(fields).Error
+		if err != nil {
+			return err
+		}
+		return logTodo(tx, userID, todo, "update")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// Trash soft delete todo, row masih ada dengan deleted_at terisi
+func (s *TodoService) Trash(ctx context.Context, userID string, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		todo, err := findTodo(tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(todo).Error; err != nil {
+			return err
+		}
+		return logTodo(tx, userID, todo, "trash")
+	})
+}
+
+// ListTrash ambil todo yang bisa dilihat user dan sudah di soft delete,
+// terbaru dulu
+func (s *TodoService) ListTrash(ctx context.Context, userID string) ([]Todo, error) {
+	var todos []Todo
+	err := s.db.WithContext(ctx).Unscoped().
+		Scopes(VisibleTodos(userID)).
+		Where("todos.deleted_at IS NOT NULL").
+		Order("deleted_at desc").
+		Find(&todos).Error
+	return todos, err
+}
+
+// Restore balikin todo dari trash dengan mengosongkan deleted_at
+func (s *TodoService) Restore(ctx context.Context, userID string, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		todo, err := findTodoWith(tx.Unscoped().Where("todos.deleted_at IS NOT NULL"), tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Model(todo).Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return logTodo(tx, userID, todo, "restore")
+	})
+}
+
+// Purge hard delete todo yang di trash sebelum olderThan, per batch biar
+// transaksinya tidak kebesaran. return jumlah row yang terhapus.
+func (s *TodoService) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	var purged int64
+	for {
+		var batch []Todo
+		err := s.db.WithContext(ctx).Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", olderThan).
+			Order("id asc").
+			Limit(TodoPurgeBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return purged, err
+		}
+		if len(batch) == 0 {
+			return purged, nil
+		}
+
+		ids := make([]uint, 0, len(batch))
+		for _, todo := range batch {
+			ids = append(ids, todo.ID)
+		}
+
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// relasi tag dan aturan berulang dihapus dulu biar foreign key tidak menolak
+			if err := tx.Exec("DELETE FROM todo_tags WHERE todo_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("template_id IN ?", ids).Delete(&TodoRecurrence{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&batch).Error; err != nil {
+				return err
+			}
+			logs := make([]*UserLog, 0, len(batch))
+			for _, todo := range batch {
+				logs = append(logs, todoLog(todo.UserID, todo, "purge"))
+			}
+			return tx.Create(&logs).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		purged += int64(len(batch))
+		if len(batch) < TodoPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// SetStatus pindah status todo sesuai aturan transisi, row di lock supaya
+// dua request bersamaan tidak saling timpa
+func (s *TodoService) SetStatus(ctx context.Context, userID string, id uint, status TodoStatus) (*Todo, error) {
+	var todo *Todo
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		todo, err = findTodoWith(tx.Clauses(clause.Locking{Strength: "UPDATE"}), tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+		if err := todo.TransitionTo(status); err != nil {
+			return err
+		}
+		if err := tx.Omit("User").Save(todo).Error; err != nil {
+			return err
+		}
+		return logTodo(tx, userID, todo, string(status))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// AddTags pasang tag ke todo, tag yang belum ada otomatis dibuat
+func (s *TodoService) AddTags(ctx context.Context, userID string, id uint, names ...string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		todo, err := findTodo(tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+
+		var tags []Tag
+		for _, name := range uniqueTagNames(names) {
+			tag := Tag{Name: name}
+			if err := tx.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return tx.Model(todo).Association("Tags").Append(tags)
+	})
+}
+
+func (s *TodoService) RemoveTags(ctx context.Context, userID string, id uint, names ...string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		todo, err := findTodo(tx, userID, id, ListRoleEditor)
+		if err != nil {
+			return err
+		}
+
+		var tags []Tag
+		if err := tx.Where("name IN ?", uniqueTagNames(names)).Find(&tags).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return tx.Model(todo).Association("Tags").Delete(tags)
+	})
+}
+
+// ListByTags ambil todo user berdasarkan tag, matchAll true berarti todo
+// harus punya semua tag, false cukup salah satu
+func (s *TodoService) ListByTags(ctx context.Context, userID string, names []string, matchAll bool) ([]Todo, error) {
+	scope := TodosWithAnyTag(names...)
+	if matchAll {
+		scope = TodosWithAllTags(names...)
+	}
+
+	var todos []Todo
+	err := s.db.WithContext(ctx).
+		Preload("Tags").
+		Scopes(scope).
+		Scopes(VisibleTodos(userID)).
+		Order("todos.id asc").
+		Find(&todos).Error
+	return todos, err
+}
+
+func findTodo(tx *gorm.DB, userID string, id uint, need ListRole) (*Todo, error) {
+	return findTodoWith(tx, tx, userID, id, need)
+}
+
+// findTodoWith cari todo lewat query (yang bisa sudah Unscoped atau di lock)
+// lalu cek role user pakai tx. todo yang tidak kelihatan sama sekali jadi
+// NotFoundError, yang kelihatan tapi role kurang jadi ErrPermissionDenied.
+func findTodoWith(query *gorm.DB, tx *gorm.DB, userID string, id uint, need ListRole) (*Todo, error) {
+	var todo Todo
+	err := query.Scopes(VisibleTodos(userID)).First(&todo, "todos.id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, todoNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role := ListRoleOwner
+	if todo.UserID != userID && todo.ListID != nil {
+		role, err = memberRole(tx, *todo.ListID, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !role.AtLeast(need) {
+		return nil, ErrPermissionDenied
+	}
+	return &todo, nil
+}
+
+func todoNotFound(id uint) error {
+	return &NotFoundError{Resource: "todo", ID: strconv.FormatUint(uint64(id), 10)}
+}
+
+// logTodo catat aksi ke user_logs, dan ke activity list kalau todonya di list
+func logTodo(tx *gorm.DB, userID string, todo *Todo, action string) error {
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(todoLog(userID, *todo, action)).Error; err != nil {
+		return err
+	}
+	if todo.ListID == nil {
+		return nil
+	}
+	return recordListActivity(tx, *todo.ListID, userID, &todo.ID, action+" todo")
+}
+
+func todoLog(userID string, todo Todo, action string) *UserLog {
+	return &UserLog{
+		UserID: userID,
+		Action: fmt.Sprintf("%s todo %d", action, todo.ID),
+	}
+}
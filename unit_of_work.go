@@ -0,0 +1,95 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork jalankan perubahan ke beberapa model dalam satu transaksi,
+// semua repository dari UnitOfWorkTx terikat ke transaksi itu
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// UnitOfWorkTx transaksi yang sedang jalan, jangan dipakai di luar fn
+type UnitOfWorkTx struct {
+	ctx         context.Context
+	db          *gorm.DB
+	afterCommit []func(ctx context.Context)
+}
+
+// Do commit kalau fn return nil, rollback kalau fn return error atau panic
+// (panic dilempar lagi setelah rollback). callback AfterCommit baru jalan
+// setelah commit berhasil.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(tx *UnitOfWorkTx) error) error {
+	work := &UnitOfWorkTx{ctx: ctx}
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		work.db = tx
+		return fn(work)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, callback := range work.afterCommit {
+		callback(ctx)
+	}
+	return nil
+}
+
+// Savepoint jalankan fn di savepoint, kalau fn gagal cuma perubahan di
+// dalam fn yang di rollback dan errornya dikembalikan ke pemanggil.
+// callback AfterCommit yang didaftarkan di fn ikut dibuang kalau gagal.
+func (t *UnitOfWorkTx) Savepoint(fn func(tx *UnitOfWorkTx) error) error {
+	nested := &UnitOfWorkTx{ctx: t.ctx}
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		nested.db = tx
+		return fn(nested)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.afterCommit = append(t.afterCommit, nested.afterCommit...)
+	return nil
+}
+
+// AfterCommit daftarkan fn yang jalan setelah transaksi paling luar commit,
+// tidak pernah jalan kalau transaksinya rollback
+func (t *UnitOfWorkTx) AfterCommit(fn func(ctx context.Context)) {
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
+// DB untuk query yang tidak ada di repository, tetap di transaksi yang sama
+func (t *UnitOfWorkTx) DB() *gorm.DB {
+	return t.db
+}
+
+func (t *UnitOfWorkTx) Users() *UserRepository {
+	return NewUserRepository(t.db)
+}
+
+func (t *UnitOfWorkTx) Wallets() *WalletRepository {
+	return NewWalletRepository(t.db)
+}
+
+func (t *UnitOfWorkTx) Addresses() *AddressRepository {
+	return NewAddressRepository(t.db)
+}
+
+func (t *UnitOfWorkTx) Products() *ProductRepository {
+	return NewProductRepository(t.db)
+}
+
+func (t *UnitOfWorkTx) Todos() *TodoRepository {
+	return NewTodoRepository(t.db)
+}
+
+func (t *UnitOfWorkTx) GuestBooks() *GuestBookRepository {
+	return NewGuestBookRepository(t.db)
+}
@@ -1,6 +1,8 @@
 package belajar_golang_gorm
 
 import (
+	"reflect"
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,16 +10,26 @@ import (
 
 // user.go
 
+// User yang di soft delete (DeletedAt) otomatis tidak ikut di query, Preload
+// dan Joins, dipakai untuk menonaktifkan akun sebelum dihapus permanen.
+// SearchName diisi hook dari Name, jangan diubah langsung. Roles bisa berisi
+// role yang sama lebih dari sekali kalau di grant di beberapa resource.
 type User struct {
-	ID           string    `gorm:"primary_key;column:id;<-:create"`
-	Password     string    `gorm:"column:password"`
-	Name         Name      `gorm:"embedded"`
-	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime;<-:create"`
-	UpdatedAt    time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
-	Information  string    `gorm:"-"`
-	Wallet       Wallet    `gorm:"foreignKey:user_id;references:id"`
-	Addresses    []Address `gorm:"foreignKey:user_id;references:id"`
-	LikedProducts []Product `gorm:"many2many:user_like_products;foreignKey:id;joinForeignKey:user_id;references:id;joinReferences:product_id"`
+	ID            string         `gorm:"primary_key;column:id;<-:create" json:"id"`
+	TenantID      TenantID       `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	Password      string         `gorm:"column:password" json:"-"`
+	Name          Name           `gorm:"embedded" json:"name"`
+	SearchName    string         `gorm:"column:search_name;size:255;index:idx_users_search_name" json:"-"`
+	CreatedAt     time.Time      `gorm:"column:created_at;autoCreateTime;<-:create" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
+	Version       Version        `gorm:"column:version;not null;default:1" json:"version"`
+	DeletedAt     gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	Information   string         `gorm:"-" json:"-"`
+	Wallet        Wallet         `gorm:"foreignKey:user_id;references:id" json:"-"`
+	Addresses     []Address      `gorm:"foreignKey:user_id;references:id" json:"addresses,omitempty"`
+	Todos         []Todo         `gorm:"foreignKey:user_id;references:id" json:"todos,omitempty"`
+	LikedProducts []Product      `gorm:"many2many:user_like_products;foreignKey:id;joinForeignKey:user_id;references:id;joinReferences:product_id" json:"liked_products,omitempty"`
+	Roles         []Role         `gorm:"many2many:user_roles;foreignKey:id;joinForeignKey:user_id;references:id;joinReferences:role_id" json:"roles,omitempty"`
 }
 
 func (u *User) TableName() string {
@@ -28,22 +40,169 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
 		u.ID = "user-" + time.Now().Format("20060102150405")
 	}
+	u.SearchName = u.Name.SearchKey()
 
 	return nil
 }
 
-type Name struct {
-	FirstName  string `gorm:"column:first_name"`
-	MiddleName string `gorm:"column:middle_name"`
-	LastName   string `gorm:"column:last_name"`
+const userSearchNameRefresh = "user:search_name_refresh"
+
+var userNameFields = []string{"FirstName", "MiddleName", "LastName"}
+
+// BeforeUpdate Save menulis semua kolom jadi search_name langsung dihitung
+// dari struct. update lain bisa cuma mengubah sebagian nama (misalnya
+// Update("last_name", ...)), jadi id row yang kena dicatat dan search_name
+// nya dihitung ulang dari database di AfterUpdate.
+func (u *User) BeforeUpdate(tx *gorm.DB) error {
+	stmt := tx.Statement
+	if slices.Contains(stmt.Selects, "*") {
+		u.SearchName = u.Name.SearchKey()
+		return nil
+	}
+	if !updatesName(stmt) {
+		return nil
+	}
+
+	query := tx.Session(&gorm.Session{NewDB: true}).Model(&User{})
+	if stmt.Unscoped {
+		query = query.Unscoped()
+	}
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		query = query.Clauses(where.Expression)
+	}
+	if u.ID != "" {
+		query = query.Where("id = ?", u.ID)
+	}
+	var ids []string
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	stmt.Settings.Store(userSearchNameRefresh, ids)
+	return nil
+}
+
+func (u *User) AfterUpdate(tx *gorm.DB) error {
+	ids, ok := tx.Statement.Settings.LoadAndDelete(userSearchNameRefresh)
+	if !ok {
+		return nil
+	}
+	return refreshSearchNames(tx.Session(&gorm.Session{NewDB: true}), ids.([]string))
+}
+
+// updatesName true kalau update menulis salah satu kolom nama, aturannya
+// sama dengan gorm: kolom di Select selalu ditulis, kolom di Omit tidak,
+// field struct yang kosong dilewati
+func updatesName(stmt *gorm.Statement) bool {
+	selected, restricted := stmt.SelectAndOmitColumns(false, true)
+	dest := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	for _, name := range userNameFields {
+		field := stmt.Schema.LookUpField(name)
+		isSelected, ok := selected[field.DBName]
+		if (ok && !isSelected) || (!ok && restricted) {
+			continue
+		}
+
+		switch values := stmt.Dest.(type) {
+		case map[string]interface{}:
+			_, byName := values[field.Name]
+			_, byColumn := values[field.DBName]
+			if byName || byColumn {
+				return true
+			}
+		default:
+			if dest.Kind() != reflect.Struct || dest.Type() != stmt.Schema.ModelType {
+				continue
+			}
+			if _, zero := field.ValueOf(stmt.Context, dest); !zero || isSelected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// refreshSearchNames hitung ulang search_name dari kolom nama di database.
+// pakai Exec supaya tidak lewat hook dan version tidak ikut naik.
+func refreshSearchNames(db *gorm.DB, ids []string) error {
+	for start := 0; start < len(ids); start += 500 {
+		var users []User
+		err := db.Unscoped().
+			Select("id", "first_name", "middle_name", "last_name", "search_name").
+			Where("id IN ?", ids[start:min(start+500, len(ids))]).
+			Find(&users).Error
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			key := user.Name.SearchKey()
+			if key == user.SearchName {
+				continue
+			}
+			if err := db.Exec("UPDATE users SET search_name = ? WHERE id = ?", key, user.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MigrateUserSoftDelete tambah kolom deleted_at di tabel users yang sudah ada,
+// AutoMigrate User tidak dipakai supaya tipe kolom id tidak ikut diubah
+func MigrateUserSoftDelete(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&User{}, "DeletedAt") {
+		if err := migrator.AddColumn(&User{}, "DeletedAt"); err != nil {
+			return err
+		}
+	}
+	if !migrator.HasIndex(&User{}, "DeletedAt") {
+		return migrator.CreateIndex(&User{}, "DeletedAt")
+	}
+	return nil
+}
+
+// MigrateUserSearchName tambah kolom search_name dan indexnya lalu isi untuk
+// user yang sudah ada, aman dijalankan ulang
+func MigrateUserSearchName(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&User{}, "SearchName") {
+		if err := migrator.AddColumn(&User{}, "SearchName"); err != nil {
+			return err
+		}
+	}
+	if !migrator.HasIndex(&User{}, "idx_users_search_name") {
+		if err := migrator.CreateIndex(&User{}, "idx_users_search_name"); err != nil {
+			return err
+		}
+	}
+
+	lastID := ""
+	for {
+		var ids []string
+		err := db.Unscoped().Model(&User{}).
+			Where("id > ?", lastID).
+			Order("id asc").
+			Limit(500).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := refreshSearchNames(db, ids); err != nil {
+			return err
+		}
+		lastID = ids[len(ids)-1]
+	}
 }
 
 type UserLog struct {
-	ID        string `gorm:"primary_key;column:id;autoIncrement"`
-	UserID    string `gorm:"column:user_id"`
-	Action    string `gorm:"column:action"`
-	CreatedAt int64  `gorm:"column:created_at;autoCreateTime:milli"`
-	UpdatedAt int64  `gorm:"column:updated_at;autoCreateTime:milli;autoUpdateTime:milli"`
+	ID        string `gorm:"primary_key;column:id;autoIncrement" json:"id"`
+	UserID    string `gorm:"column:user_id" json:"user_id"`
+	Action    string `gorm:"column:action" json:"action"`
+	CreatedAt int64  `gorm:"column:created_at;autoCreateTime:milli" json:"created_at"`
+	UpdatedAt int64  `gorm:"column:updated_at;autoCreateTime:milli;autoUpdateTime:milli" json:"updated_at"`
 }
 
 func (u *UserLog) TableName() string {
@@ -0,0 +1,83 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserRetentionJob hapus permanen user yang sudah di soft delete lebih lama
+// dari Retention. user yang masih kena aturan restrict (misalnya masih punya
+// wallet) dilewati dan dicoba lagi di putaran berikutnya.
+type UserRetentionJob struct {
+	db        *gorm.DB
+	users     *UserService
+	Retention time.Duration
+	BatchSize int
+	// jeda antar putaran waktu jalan sebagai goroutine
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+func NewUserRetentionJob(db *gorm.DB, users *UserService) *UserRetentionJob {
+	return &UserRetentionJob{
+		db:        db,
+		users:     users,
+		Retention: 30 * 24 * time.Hour,
+		BatchSize: 100,
+		Interval:  time.Hour,
+		Now:       time.Now,
+	}
+}
+
+// Run jalankan RunOnce terus menerus sampai ctx dibatalkan
+func (j *UserRetentionJob) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce return jumlah user yang dihapus permanen. tiap user dihapus di
+// transaksinya sendiri seperti UserService.Purge.
+func (j *UserRetentionJob) RunOnce(ctx context.Context) (int, error) {
+	cutoff := j.Now().Add(-j.Retention)
+
+	purged := 0
+	lastID := ""
+	for {
+		var ids []string
+		err := j.db.WithContext(ctx).Unscoped().Model(&User{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Where("id > ?", lastID).
+			Order("id asc").
+			Limit(j.BatchSize).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		for _, id := range ids {
+			lastID = id
+			err := j.users.purge(ctx, id, &cutoff)
+			if errors.Is(err, ErrDeleteRestricted) || errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"belajar_golang_gorm/filter"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+type UserService struct {
+	db *gorm.DB
+	// SoftDeleteRules dijalankan waktu Delete, DeleteRules waktu Purge
+	SoftDeleteRules []DeleteRule
+	DeleteRules     []DeleteRule
+}
+
+func NewUserService(db *gorm.DB) *UserService {
+	return &UserService{
+		db:              db,
+		SoftDeleteRules: DefaultUserSoftDeleteRules,
+		DeleteRules:     DefaultUserDeleteRules,
+	}
+}
+
+// UserUpdate field yang nil tidak diubah. kalau Version diisi update
+// ditolak dengan ErrStaleObject saat user sudah diubah orang lain.
+type UserUpdate struct {
+	Password *string
+	Name     *Name
+	Version  *Version
+}
+
+func (s *UserService) Create(ctx context.Context, user *User) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(user).Error
+}
+
+func (s *UserService) Get(ctx context.Context, id string) (*User, error) {
+	var user User
+	err := s.db.WithContext(ctx).Preload("Addresses").Take(&user, "id = ?", id).Error
+	if err != nil {
+		return nil, notFound(err, "user", id)
+	}
+	return &user, nil
+}
+
+// List query dari filter.Parse dengan UserFilterSchema, boleh nil.
+// id selalu jadi urutan terakhir supaya hasilnya stabil.
+func (s *UserService) List(ctx context.Context, query *filter.Query, limit int, offset int) ([]User, error) {
+	var users []User
+	err := s.db.WithContext(ctx).
+		Scopes(query.Scope).
+		Order("id asc").
+		Limit(listLimit(limit)).
+		Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// SearchByName cari user yang nama lengkapnya diawali prefix, tanpa beda
+// huruf besar dan aksen. pakai index search_name, jadi cuma cocok dari awal
+// nama depan. spasi di akhir prefix berarti katanya harus sudah lengkap.
+func (s *UserService) SearchByName(ctx context.Context, prefix string, limit int, offset int) ([]User, error) {
+	key := NormalizeSearchText(prefix)
+	if key == "" {
+		return []User{}, nil
+	}
+	if strings.TrimRightFunc(prefix, unicode.IsSpace) != prefix {
+		key += " "
+	}
+
+	var users []User
+	err := s.db.WithContext(ctx).
+		Where("search_name LIKE ?", key+"%").
+		Order("search_name asc").
+		Order("id asc").
+		Limit(listLimit(limit)).
+		Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+func (s *UserService) Update(ctx context.Context, id string, update UserUpdate) (*User, error) {
+	values := map[string]interface{}{}
+	if update.Password != nil {
+		values["password"] = *update.Password
+	}
+	if update.Name != nil {
+		values["first_name"] = update.Name.FirstName
+		values["middle_name"] = update.Name.MiddleName
+		values["last_name"] = update.Name.LastName
+	}
+	if update.Version != nil && len(values) > 0 {
+		values["version"] = *update.Version
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", id).Updates(values)
+		if errors.Is(result.Error, ErrStaleObject) {
+			return staleOrNotFound(tx, result.Error, &User{}, "user", id)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return requireExists(tx, &User{}, "user", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete soft delete, user tidak bisa login dan tidak kelihatan di query
+// lain sampai diaktifkan lagi dengan Reactivate atau dihapus permanen oleh
+// UserRetentionJob
+func (s *UserService) Delete(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", id); err != nil {
+			return err
+		}
+		now := tx.NowFunc()
+		userIDs := tx.Table("users").Select("id").Where("id = ?", id)
+		if err := applyDeleteRules(tx, s.SoftDeleteRules, userIDs, now, "user", id); err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserLog{UserID: id, Action: "deactivate user"}).Error
+	})
+}
+
+// Reactivate batalkan Delete, data yang ikut di soft delete bersama user
+// dikembalikan. user yang masih aktif dikembalikan apa adanya.
+func (s *UserService) Reactivate(ctx context.Context, id string) (*User, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Take(&user, "id = ?", id).Error
+		if err != nil {
+			return notFound(err, "user", id)
+		}
+		if !user.DeletedAt.Valid {
+			return nil
+		}
+
+		userIDs := tx.Table("users").Select("id").Where("id = ?", id)
+		if err := restoreDeleteRules(tx, s.SoftDeleteRules, userIDs, user.DeletedAt.Time); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&User{}).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserLog{UserID: id, Action: "reactivate user"}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Purge hapus permanen, aktif atau sudah di soft delete. DeleteRules
+// dijalankan di transaksi yang sama, kalau ada aturan restrict yang kena user
+// tidak dihapus dan error nya RestrictedError.
+func (s *UserService) Purge(ctx context.Context, id string) error {
+	return s.purge(ctx, id, nil)
+}
+
+// purge kalau deletedBefore diisi user cuma dihapus kalau masih di soft
+// delete sebelum waktu itu, jadi user yang baru diaktifkan lagi aman
+func (s *UserService) purge(ctx context.Context, id string, deletedBefore *time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+		if deletedBefore != nil {
+			query = query.Where("deleted_at < ?", *deletedBefore)
+		}
+		var user User
+		if err := query.Take(&user).Error; err != nil {
+			return notFound(err, "user", id)
+		}
+
+		userIDs := tx.Table("users").Select("id").Where("id = ?", id)
+		if err := applyDeleteRules(tx, s.DeleteRules, userIDs, tx.NowFunc(), "user", id); err != nil {
+			return err
+		}
+		return deleteOne(tx.Unscoped().Where("id = ?", id), &User{}, "user", id)
+	})
+}
+
+// notFound ubah gorm.ErrRecordNotFound jadi NotFoundError, error lain dibiarkan
+func notFound(err error, resource string, id string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return err
+}
+
+// requireExists dipakai setelah update yang RowsAffected 0, karena mysql
+// juga return 0 kalau nilainya sama dengan yang lama
+func requireExists(tx *gorm.DB, model interface{}, resource string, id string) error {
+	var count int64
+	if err := tx.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return nil
+}
+
+// staleOrNotFound update dengan version yang tidak kena row bisa karena
+// version sudah berubah atau karena row memang tidak ada
+func staleOrNotFound(tx *gorm.DB, err error, model interface{}, resource string, id string) error {
+	if existsErr := requireExists(tx, model, resource, id); existsErr != nil {
+		return existsErr
+	}
+	return err
+}
+
+func deleteOne(query *gorm.DB, model interface{}, resource string, id string) error {
+	result := query.Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return nil
+}
+
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultListLimit
+	}
+	return min(limit, MaxListLimit)
+}
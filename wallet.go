@@ -3,13 +3,14 @@ package belajar_golang_gorm
 import "time"
 
 type Wallet struct {
-	ID        string    `gorm:"primary_key;column:id"`
-	UserID    string    `gorm:"column:user_id"`
-	Balance   int64     `gorm:"column:balance"`
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime"`
+	ID        string    `gorm:"primary_key;column:id" json:"id"`
+	TenantID  TenantID  `gorm:"column:tenant_id;size:100;index;<-:create" json:"-"`
+	UserID    string    `gorm:"column:user_id" json:"user_id"`
+	Balance   int64     `gorm:"column:balance" json:"balance"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoCreateTime;autoUpdateTime" json:"updated_at"`
 	// user kalau kagak di pointer bakalan cyclic
-	User      *User     `gorm:"foreignKey:user_id;references:id"`
+	User *User `gorm:"foreignKey:user_id;references:id" json:"-"`
 }
 
 func (w *Wallet) TableName() string {
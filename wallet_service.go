@@ -0,0 +1,68 @@
+package belajar_golang_gorm
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// WalletService satu user satu wallet, jadi semua method pakai userID
+type WalletService struct {
+	db *gorm.DB
+}
+
+func NewWalletService(db *gorm.DB) *WalletService {
+	return &WalletService{db: db}
+}
+
+func (s *WalletService) Get(ctx context.Context, userID string) (*Wallet, error) {
+	var wallet Wallet
+	err := s.db.WithContext(ctx).Take(&wallet, "user_id = ?", userID).Error
+	if err != nil {
+		return nil, notFound(err, "wallet of user", userID)
+	}
+	return &wallet, nil
+}
+
+func (s *WalletService) Create(ctx context.Context, userID string, wallet *Wallet) error {
+	wallet.UserID = userID
+	if wallet.ID == "" {
+		wallet.ID = userID
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireExists(tx, &User{}, "user", userID); err != nil {
+			return err
+		}
+		return tx.Omit(clause.Associations).Create(wallet).Error
+	})
+}
+
+// Adjust tambah (amount positif) atau kurangi (negatif) saldo, row di lock
+// seperti di TestLock supaya dua transaksi tidak saling timpa
+func (s *WalletService) Adjust(ctx context.Context, userID string, amount int64) (*Wallet, error) {
+	var wallet Wallet
+	err := RetryTransaction(ctx, s.db, RetryOptions{Name: "wallet.adjust"}, func(tx *gorm.DB) error {
+		wallet = Wallet{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&wallet, "user_id = ?", userID).Error
+		if err != nil {
+			return notFound(err, "wallet of user", userID)
+		}
+		if wallet.Balance+amount < 0 {
+			return ErrInsufficientBalance
+		}
+		wallet.Balance += amount
+		return tx.Model(&wallet).Update("balance", wallet.Balance).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (s *WalletService) Delete(ctx context.Context, userID string) error {
+	return deleteOne(s.db.WithContext(ctx).Where("user_id = ?", userID), &Wallet{}, "wallet of user", userID)
+}